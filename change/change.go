@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/rschoonheim/go-struct-sync/compare"
 	"reflect"
+	"strings"
 )
 
 // ApplyChanges applies a list of changes to the original struct and returns a modified copy
@@ -20,6 +21,12 @@ func ApplyChanges(original interface{}, changes []compare.Change) (interface{},
 		return nil, fmt.Errorf("original must be a struct")
 	}
 
+	// Carry changes to fields recorded against earlier versions of the type over to the current ones
+	changes, err := compare.MigrateUnknownFields(original, changes)
+	if err != nil {
+		return nil, err
+	}
+
 	originalType := originalVal.Type()
 
 	// Create a new instance
@@ -34,15 +41,21 @@ func ApplyChanges(original interface{}, changes []compare.Change) (interface{},
 
 	// Create a field cache to avoid repeated lookups
 	fieldCache := make(map[string]reflect.Value, len(changes))
+	copied := make(map[string]bool)
 
 	// Apply each change
 	for _, change := range changes {
 		// Check cache first before using reflection to find the field
 		field, ok := fieldCache[change.Field]
 		if !ok {
-			field = resultVal.FieldByName(change.Field)
+			var err error
+			// Deleting below a nil pointer has nothing to clear, so it must not allocate the parent
+			field, err = fieldByPath(resultVal, change.Field, copied, change.ChangeType != compare.Deleted)
+			if err != nil {
+				return nil, err
+			}
 			if !field.IsValid() {
-				return nil, fmt.Errorf("field %s not found", change.Field)
+				continue
 			}
 			fieldCache[change.Field] = field
 		}
@@ -51,11 +64,17 @@ func ApplyChanges(original interface{}, changes []compare.Change) (interface{},
 			return nil, fmt.Errorf("field %s is not settable", change.Field)
 		}
 
+		// Writing a struct or pointer replaces everything below it, so fields found under it before are
+		// stale and a pointer set from the change must be copied again before it is written through
+		if field.Kind() == reflect.Struct || field.Kind() == reflect.Ptr {
+			forget(change.Field, fieldCache, copied)
+		}
+
 		switch change.ChangeType {
 		case compare.Deleted:
 			// Set zero value for deleted fields
 			field.Set(reflect.Zero(field.Type()))
-		case compare.Modified, compare.Added:
+		case compare.Modified, compare.Added, compare.TypeChanged:
 			// Fast path for nil values
 			if change.NewValue == nil {
 				if field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface ||
@@ -68,13 +87,19 @@ func ApplyChanges(original interface{}, changes []compare.Change) (interface{},
 			// Handle non-nil values
 			newValue := reflect.ValueOf(change.NewValue)
 
+			// A type change must carry a value of the recorded dynamic type
+			if change.ChangeType == compare.TypeChanged && change.NewType != "" && compare.TypeName(change.NewValue) != change.NewType {
+				return nil, fmt.Errorf("field %s expects a value of type %s, got %s (is the type registered?)",
+					change.Field, change.NewType, compare.TypeName(change.NewValue))
+			}
+
 			// Direct set if types match
 			if field.Type() == newValue.Type() {
 				field.Set(newValue)
-			} else if newValue.Type().ConvertibleTo(field.Type()) {
-				field.Set(newValue.Convert(field.Type()))
+			} else if converted, err := convertValue(newValue, field.Type()); err == nil {
+				field.Set(converted)
 			} else {
-				return nil, fmt.Errorf("cannot convert value for field %s", change.Field)
+				return nil, fmt.Errorf("cannot convert value for field %s: %w", change.Field, err)
 			}
 		}
 	}
@@ -87,3 +112,68 @@ func ApplyChanges(original interface{}, changes []compare.Change) (interface{},
 	}
 	return resultVal.Interface(), nil
 }
+
+// ApplyChangesMasked applies changes like ApplyChanges after checking that every change is covered by
+// mask, returning a *compare.MaskError for the first change outside it
+func ApplyChangesMasked(original interface{}, changes []compare.Change, mask []string) (interface{}, error) {
+	if err := compare.CheckMask(changes, mask); err != nil {
+		return nil, err
+	}
+	return ApplyChanges(original, changes)
+}
+
+// forget - drops the cached fields and copied pointers at path and below it
+func forget(path string, fieldCache map[string]reflect.Value, copied map[string]bool) {
+	for cached := range fieldCache {
+		if cached == path || strings.HasPrefix(cached, path+".") {
+			delete(fieldCache, cached)
+		}
+	}
+	for prefix := range copied {
+		if prefix == path || strings.HasPrefix(prefix, path+".") {
+			delete(copied, prefix)
+		}
+	}
+}
+
+// fieldByPath - finds the field at a dotted path such as "Manager.Name". Structs reached through a
+// pointer are copied before they are written so the original is left untouched, and copied records the
+// paths whose pointer already refers to a copy. A nil pointer along the path is allocated when allocate
+// is set, otherwise an invalid value is returned.
+func fieldByPath(v reflect.Value, path string, copied map[string]bool, allocate bool) (reflect.Value, error) {
+	segments := strings.Split(path, ".")
+	for i, name := range segments {
+		field := v.FieldByName(name)
+		if !field.IsValid() {
+			return reflect.Value{}, fmt.Errorf("field %s not found", path)
+		}
+		if i == len(segments)-1 {
+			return field, nil
+		}
+
+		prefix := strings.Join(segments[:i+1], ".")
+		switch {
+		case field.Kind() == reflect.Struct:
+			v = field
+		case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct:
+			if !field.CanSet() {
+				return reflect.Value{}, fmt.Errorf("field %s is not settable", prefix)
+			}
+			if field.IsNil() && !allocate {
+				return reflect.Value{}, nil
+			}
+			if !copied[prefix] {
+				copy := reflect.New(field.Type().Elem())
+				if !field.IsNil() {
+					copy.Elem().Set(field.Elem())
+				}
+				field.Set(copy)
+				copied[prefix] = true
+			}
+			v = field.Elem()
+		default:
+			return reflect.Value{}, fmt.Errorf("field %s is not a struct", prefix)
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("field %s not found", path)
+}
@@ -1,6 +1,7 @@
 package change
 
 import (
+	"errors"
 	"github.com/rschoonheim/go-struct-sync/compare"
 	"reflect"
 	"testing"
@@ -184,3 +185,273 @@ func TestApplyChangesFailsOnTypeConversionError(t *testing.T) {
 		t.Error("Expected error when type conversion isn't possible")
 	}
 }
+
+type Notifier interface {
+	Notify(message string) string
+}
+
+type EmailNotifier struct {
+	Address string
+}
+
+func (n *EmailNotifier) Notify(message string) string { return n.Address + ": " + message }
+
+type SMSNotifier struct {
+	Number string
+}
+
+func (n *SMSNotifier) Notify(message string) string { return n.Number + ": " + message }
+
+type Subscription struct {
+	Topic    string
+	Notifier Notifier
+}
+
+func TestApplyChangesAppliesTypeChanged(t *testing.T) {
+	original := Subscription{Topic: "news", Notifier: &EmailNotifier{Address: "a@example.com"}}
+	updated := Subscription{Topic: "news", Notifier: &SMSNotifier{Number: "555"}}
+
+	changes, err := compare.CompareStructs(original, updated)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	result, err := ApplyChanges(original, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	sms, ok := result.(Subscription).Notifier.(*SMSNotifier)
+	if !ok || sms.Number != "555" {
+		t.Errorf("Expected *SMSNotifier after applying type change, got %#v", result.(Subscription).Notifier)
+	}
+}
+
+func TestApplyChangesAppliesTypeChangedAfterJSONRoundTrip(t *testing.T) {
+	compare.RegisterType(&SMSNotifier{})
+
+	original := Subscription{Notifier: &EmailNotifier{Address: "a@example.com"}}
+	changes, err := compare.CompareStructs(original, Subscription{Notifier: &SMSNotifier{Number: "555"}})
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	jsonData, err := compare.ChangesToJSON(changes)
+	if err != nil {
+		t.Fatalf("ChangesToJSON failed: %v", err)
+	}
+	restored, err := compare.ChangesFromJSON(jsonData)
+	if err != nil {
+		t.Fatalf("ChangesFromJSON failed: %v", err)
+	}
+
+	result, err := ApplyChanges(original, restored)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	if _, ok := result.(Subscription).Notifier.(*SMSNotifier); !ok {
+		t.Errorf("Expected *SMSNotifier after round trip, got %#v", result.(Subscription).Notifier)
+	}
+}
+
+func TestApplyChangesFailsOnTypeChangedWithWrongValueType(t *testing.T) {
+	original := Subscription{Notifier: &EmailNotifier{}}
+	changes := []compare.Change{
+		{Field: "Notifier", ChangeType: compare.TypeChanged, NewValue: &EmailNotifier{}, NewType: "*example.com/notify.SMSNotifier"},
+	}
+
+	_, err := ApplyChanges(original, changes)
+	if err == nil {
+		t.Error("Expected error when value does not match the recorded type")
+	}
+}
+
+func TestApplyChangesFromMapComparison(t *testing.T) {
+	type Account struct {
+		ID    int64  `json:"id"`
+		Email string `json:"email"`
+	}
+
+	original := Account{ID: 1, Email: "old@example.com"}
+	changes, err := compare.CompareStructToJSON(original, []byte(`{"id": 2, "email": "new@example.com"}`))
+	if err != nil {
+		t.Fatalf("CompareStructToJSON failed: %v", err)
+	}
+
+	result, err := ApplyChanges(original, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	if modified := result.(Account); modified.ID != 2 || modified.Email != "new@example.com" {
+		t.Errorf("Expected payload to be applied, got %+v", modified)
+	}
+}
+
+func TestApplyChangesFromMappedComparison(t *testing.T) {
+	type User struct {
+		FullName string
+		Age      int
+	}
+	type UserDTO struct {
+		Name string `sync:"field=FullName"`
+		Age  int32
+	}
+
+	original := User{FullName: "John", Age: 30}
+	changes, err := compare.CompareMapped(UserDTO{Name: "Jane", Age: 31}, original, compare.TagMapper)
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+
+	result, err := ApplyChanges(original, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	if modified := result.(User); modified.FullName != "Jane" || modified.Age != 31 {
+		t.Errorf("Expected DTO values applied to domain type, got %+v", modified)
+	}
+}
+
+func TestApplyChangesAppliesNestedPaths(t *testing.T) {
+	original := Person{Name: "John", Manager: &Person{Name: "Boss", Age: 50}}
+	changes, err := compare.CompareStructsDeep(original, Person{Name: "John", Manager: &Person{Name: "Chief", Age: 51}})
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	result, err := ApplyChanges(original, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	modified := result.(Person)
+	if modified.Manager.Name != "Chief" || modified.Manager.Age != 51 {
+		t.Errorf("Expected nested fields to be applied, got %+v", modified.Manager)
+	}
+	if original.Manager.Name != "Boss" || original.Manager.Age != 50 {
+		t.Errorf("Expected the original manager to be unchanged, got %+v", original.Manager)
+	}
+}
+
+func TestApplyChangesAllocatesNilStructsOnNestedPaths(t *testing.T) {
+	result, err := ApplyChanges(Person{}, []compare.Change{{Field: "Manager.Name", ChangeType: compare.Added, NewValue: "Boss"}})
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if manager := result.(Person).Manager; manager == nil || manager.Name != "Boss" {
+		t.Errorf("Expected manager to be allocated, got %+v", manager)
+	}
+
+	if _, err := ApplyChanges(Person{}, []compare.Change{{Field: "Name.First", NewValue: "x"}}); err == nil {
+		t.Error("Expected error when descending into a non-struct field")
+	}
+}
+
+func TestApplyChangesWritesNestedPathsUnderReplacedParents(t *testing.T) {
+	replacement := &Person{Name: "Chief", Age: 60}
+	changes := []compare.Change{
+		{Field: "Manager.Name", ChangeType: compare.Modified, NewValue: "Lead"},
+		{Field: "Manager", ChangeType: compare.Modified, NewValue: replacement},
+		{Field: "Manager.Age", ChangeType: compare.Modified, NewValue: 61},
+		{Field: "Manager.Name", ChangeType: compare.Modified, NewValue: "Director"},
+	}
+
+	result, err := ApplyChanges(Person{Manager: &Person{Name: "Boss", Age: 50}}, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if manager := result.(Person).Manager; manager.Name != "Director" || manager.Age != 61 {
+		t.Errorf("Expected nested fields to be written to the new manager, got %+v", manager)
+	}
+	if replacement.Name != "Chief" || replacement.Age != 60 {
+		t.Errorf("Expected the replacement value to be unchanged, got %+v", replacement)
+	}
+}
+
+func TestApplyChangesSkipsDeletesUnderNilPointers(t *testing.T) {
+	result, err := ApplyChanges(Person{Name: "John"}, []compare.Change{{Field: "Manager.Name", ChangeType: compare.Deleted, OldValue: "Boss"}})
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if manager := result.(Person).Manager; manager != nil {
+		t.Errorf("Expected manager to stay nil, got %+v", manager)
+	}
+}
+
+func TestApplyChangesMaskedRejectsChangesOutsideMask(t *testing.T) {
+	original := Person{Name: "John", Age: 30}
+	changes := []compare.Change{
+		{Field: "Name", ChangeType: compare.Modified, OldValue: "John", NewValue: "Jane"},
+		{Field: "Age", ChangeType: compare.Modified, OldValue: 30, NewValue: 31},
+	}
+
+	_, err := ApplyChangesMasked(original, changes, []string{"Name"})
+	var maskErr *compare.MaskError
+	if !errors.As(err, &maskErr) || maskErr.Field != "Age" {
+		t.Fatalf("Expected MaskError for Age, got %v", err)
+	}
+
+	result, err := ApplyChangesMasked(original, changes, []string{"Name", "Age"})
+	if err != nil {
+		t.Fatalf("ApplyChangesMasked failed: %v", err)
+	}
+	if modified := result.(Person); modified.Name != "Jane" || modified.Age != 31 {
+		t.Errorf("Expected changes to be applied, got %+v", modified)
+	}
+}
+
+type AccountV2 struct {
+	Email   string
+	Balance int64
+}
+
+func init() {
+	// v1 -> v2: Mail became Email and Legacy was dropped
+	compare.RegisterMigration(AccountV2{}, compare.Migration{
+		From:   "v1",
+		Rename: map[string]string{"Mail": "Email"},
+		Drop:   []string{"Legacy"},
+	})
+}
+
+func TestApplyChangesAppliesMigratedChangeSets(t *testing.T) {
+	typeName := compare.TypeName(AccountV2{})
+	data := []byte(`{"id":"1","type_name":"` + typeName + `","schema_hash":"v1","changes":[
+		{"Field":"Mail","ChangeType":"modified","OldValue":"a@example.com","NewValue":"b@example.com"},
+		{"Field":"Legacy","ChangeType":"added","OldValue":null,"NewValue":true},
+		{"Field":"Balance","ChangeType":"modified","OldValue":10,"NewValue":25}]}`)
+	cs, err := compare.ChangeSetFromJSON(data)
+	if err != nil {
+		t.Fatalf("ChangeSetFromJSON failed: %v", err)
+	}
+	if err := cs.AppliesTo(AccountV2{}); err != nil {
+		t.Fatalf("Expected the migrated change set to apply, got %v", err)
+	}
+
+	result, err := ApplyChanges(AccountV2{Email: "a@example.com", Balance: 10}, cs.Changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if account := result.(AccountV2); account.Email != "b@example.com" || account.Balance != 25 {
+		t.Errorf("Expected migrated changes to be applied, got %+v", account)
+	}
+}
+
+func TestApplyChangesMigratesChangesToRemovedFields(t *testing.T) {
+	changes := []compare.Change{
+		{Field: "Mail", ChangeType: compare.Modified, OldValue: "a@example.com", NewValue: "b@example.com"},
+		{Field: "Legacy", ChangeType: compare.Added, NewValue: true},
+		{Field: "Balance", ChangeType: compare.Modified, OldValue: int64(10), NewValue: int64(25)},
+	}
+
+	result, err := ApplyChanges(AccountV2{Email: "a@example.com", Balance: 10}, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if account := result.(AccountV2); account.Email != "b@example.com" || account.Balance != 25 {
+		t.Errorf("Expected migrated changes to be applied, got %+v", account)
+	}
+}
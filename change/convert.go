@@ -0,0 +1,145 @@
+package change
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// converterKey identifies a registered converter by its source and target types
+type converterKey struct {
+	from, to reflect.Type
+}
+
+// errNoConversion is returned when no built-in conversion applies to a pair of types
+var errNoConversion = errors.New("no conversion")
+
+// converters holds the registered converter functions keyed by converterKey
+var converters sync.Map
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// RegisterConverter registers convert for setting fields of the type of to from values of the type of
+// from, e.g. RegisterConverter("", Money{}, parseMoney). A registered converter takes precedence over
+// the built-in conversions and the field type's UnmarshalText and UnmarshalJSON methods, and
+// registering the same pair again replaces it.
+func RegisterConverter(from, to interface{}, convert func(value interface{}) (interface{}, error)) {
+	if from == nil || to == nil || convert == nil {
+		return
+	}
+	converters.Store(converterKey{reflect.TypeOf(from), reflect.TypeOf(to)}, convert)
+}
+
+// convertValue - converts value to target, using a registered converter, a built-in lossless conversion
+// or the unmarshal methods of target
+func convertValue(value reflect.Value, target reflect.Type) (reflect.Value, error) {
+	if convert, ok := converters.Load(converterKey{value.Type(), target}); ok {
+		converted, err := convert.(func(interface{}) (interface{}, error))(value.Interface())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result := reflect.ValueOf(converted)
+		if !result.IsValid() || !result.Type().AssignableTo(target) {
+			return reflect.Value{}, fmt.Errorf("converter returned %T, expected %s", converted, target)
+		}
+		return result, nil
+	}
+
+	converted, err := convertBuiltin(value, target)
+	if err == nil {
+		return converted, nil
+	}
+
+	// Types like net.IP and enums parse their own text, which must win over Go's conversion of a
+	// string into a byte slice
+	if converted, ok, err := unmarshalValue(value, target); ok {
+		return converted, err
+	}
+	if errors.Is(err, errNoConversion) && value.Type().ConvertibleTo(target) {
+		return value.Convert(target), nil
+	}
+	return reflect.Value{}, err
+}
+
+// convertBuiltin - converts value to target using the built-in lossless conversions
+func convertBuiltin(value reflect.Value, target reflect.Type) (reflect.Value, error) {
+	switch {
+	case value.Kind() == reflect.String && target == timeType:
+		t, err := time.Parse(time.RFC3339Nano, value.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(t), nil
+	case value.Type() == timeType && target.Kind() == reflect.String:
+		text := value.Interface().(time.Time).Format(time.RFC3339Nano)
+		return reflect.ValueOf(text).Convert(target), nil
+	case value.Kind() == reflect.String && target == durationType:
+		d, err := time.ParseDuration(value.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	case value.Kind() == reflect.String && target.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b).Convert(target), nil
+	case value.Kind() == reflect.Bool && target.Kind() == reflect.String:
+		return reflect.ValueOf(strconv.FormatBool(value.Bool())).Convert(target), nil
+	}
+
+	if converted, ok, err := compare.ConvertNumber(value, target); ok {
+		return converted, err
+	}
+	return reflect.Value{}, fmt.Errorf("%w from %s to %s", errNoConversion, value.Type(), target)
+}
+
+// unmarshalValue - sets a new value of target, or of the type it points to, from its UnmarshalText
+// method for text values or its UnmarshalJSON method for any value, reporting whether target has one
+func unmarshalValue(value reflect.Value, target reflect.Type) (reflect.Value, bool, error) {
+	elem := target
+	if target.Kind() == reflect.Ptr {
+		elem = target.Elem()
+	}
+	ptr := reflect.New(elem)
+	result := ptr.Elem()
+	if target.Kind() == reflect.Ptr {
+		result = ptr
+	}
+
+	isText := value.Kind() == reflect.String || (value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8)
+	if unmarshaler, ok := ptr.Interface().(encoding.TextUnmarshaler); ok && isText {
+		var text []byte
+		if value.Kind() == reflect.String {
+			text = []byte(value.String())
+		} else {
+			text = value.Bytes()
+		}
+		if err := unmarshaler.UnmarshalText(text); err != nil {
+			return reflect.Value{}, true, err
+		}
+		return result, true, nil
+	}
+
+	if unmarshaler, ok := ptr.Interface().(json.Unmarshaler); ok {
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return reflect.Value{}, true, err
+		}
+		if err := unmarshaler.UnmarshalJSON(data); err != nil {
+			return reflect.Value{}, true, err
+		}
+		return result, true, nil
+	}
+	return reflect.Value{}, false, nil
+}
@@ -0,0 +1,200 @@
+package change
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Measurement struct {
+	Count    int
+	Total    int64
+	Small    int8
+	Unsigned uint16
+	Ratio    float32
+	Label    string
+	Enabled  bool
+	Taken    time.Time
+	Timeout  time.Duration
+	Price    Money
+}
+
+type Money struct {
+	Cents int64
+}
+
+func TestApplyChangesConvertsLosslessValues(t *testing.T) {
+	taken := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	changes := []compare.Change{
+		{Field: "Count", ChangeType: compare.Modified, NewValue: "42"},
+		{Field: "Total", ChangeType: compare.Modified, NewValue: float64(1 << 40)},
+		{Field: "Small", ChangeType: compare.Modified, NewValue: -128},
+		{Field: "Unsigned", ChangeType: compare.Modified, NewValue: float64(65535)},
+		{Field: "Ratio", ChangeType: compare.Modified, NewValue: 0.1},
+		{Field: "Label", ChangeType: compare.Modified, NewValue: 7},
+		{Field: "Enabled", ChangeType: compare.Modified, NewValue: "true"},
+		{Field: "Taken", ChangeType: compare.Modified, NewValue: "2024-05-01T12:30:00Z"},
+		{Field: "Timeout", ChangeType: compare.Modified, NewValue: "1m30s"},
+	}
+
+	result, err := ApplyChanges(Measurement{}, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	m := result.(Measurement)
+	if m.Count != 42 || m.Total != 1<<40 || m.Small != -128 || m.Unsigned != 65535 {
+		t.Errorf("Unexpected integers %d %d %d %d", m.Count, m.Total, m.Small, m.Unsigned)
+	}
+	if m.Ratio != 0.1 {
+		t.Errorf("Expected ratio 0.1, got %v", m.Ratio)
+	}
+	if m.Label != "7" {
+		t.Errorf("Expected label \"7\", got %q", m.Label)
+	}
+	if !m.Enabled || !m.Taken.Equal(taken) || m.Timeout != 90*time.Second {
+		t.Errorf("Unexpected values %v %v %v", m.Enabled, m.Taken, m.Timeout)
+	}
+}
+
+func TestApplyChangesRejectsLossyConversions(t *testing.T) {
+	tests := []struct {
+		field string
+		value interface{}
+	}{
+		{"Count", 3.5},
+		{"Small", 200},
+		{"Unsigned", -1},
+		{"Total", "9223372036854775808"},
+		{"Count", "forty-two"},
+		{"Ratio", 1e300},
+		{"Ratio", 0.1234567891},
+		{"Taken", "yesterday"},
+		{"Count", struct{}{}},
+	}
+
+	for _, test := range tests {
+		changes := []compare.Change{{Field: test.field, ChangeType: compare.Modified, NewValue: test.value}}
+		if _, err := ApplyChanges(Measurement{}, changes); err == nil {
+			t.Errorf("Expected error converting %v into %s", test.value, test.field)
+		} else if !strings.Contains(err.Error(), "cannot convert value for field "+test.field) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+}
+
+func TestApplyChangesUsesRegisteredConverter(t *testing.T) {
+	invalid := errors.New("invalid amount")
+	RegisterConverter("", Money{}, func(value interface{}) (interface{}, error) {
+		var whole, cents int64
+		if _, err := fmt.Sscanf(value.(string), "%d.%02d", &whole, &cents); err != nil {
+			return nil, invalid
+		}
+		return Money{Cents: whole*100 + cents}, nil
+	})
+
+	result, err := ApplyChanges(Measurement{}, []compare.Change{
+		{Field: "Price", ChangeType: compare.Modified, NewValue: "12.34"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+	if price := result.(Measurement).Price; price.Cents != 1234 {
+		t.Errorf("Expected 1234 cents, got %d", price.Cents)
+	}
+
+	_, err = ApplyChanges(Measurement{}, []compare.Change{
+		{Field: "Price", ChangeType: compare.Modified, NewValue: "free"},
+	})
+	if !errors.Is(err, invalid) {
+		t.Errorf("Expected converter error, got %v", err)
+	}
+}
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+)
+
+func (l *Level) UnmarshalText(text []byte) error {
+	for i, name := range []string{"debug", "info", "warn"} {
+		if string(text) == name {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var pair [2]int
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	*p = Point{X: pair[0], Y: pair[1]}
+	return nil
+}
+
+type Endpoint struct {
+	Address  net.IP
+	Level    Level
+	Fallback *Level
+	Origin   Point
+}
+
+func TestApplyChangesFallsBackToUnmarshalers(t *testing.T) {
+	changes := []compare.Change{
+		{Field: "Address", ChangeType: compare.Modified, NewValue: "10.0.0.1"},
+		{Field: "Level", ChangeType: compare.Modified, NewValue: "warn"},
+		{Field: "Fallback", ChangeType: compare.Added, NewValue: "info"},
+		{Field: "Origin", ChangeType: compare.Modified, NewValue: []interface{}{float64(3), float64(4)}},
+	}
+
+	result, err := ApplyChanges(Endpoint{}, changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	e := result.(Endpoint)
+	if !e.Address.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected address 10.0.0.1, got %v", e.Address)
+	}
+	if e.Level != Warn {
+		t.Errorf("Expected level %d, got %d", Warn, e.Level)
+	}
+	if e.Fallback == nil || *e.Fallback != Info {
+		t.Errorf("Expected fallback level %d, got %v", Info, e.Fallback)
+	}
+	if e.Origin != (Point{X: 3, Y: 4}) {
+		t.Errorf("Expected origin {3 4}, got %v", e.Origin)
+	}
+}
+
+func TestApplyChangesReportsUnmarshalErrors(t *testing.T) {
+	_, err := ApplyChanges(Endpoint{}, []compare.Change{
+		{Field: "Level", ChangeType: compare.Modified, NewValue: "verbose"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown level") {
+		t.Errorf("Expected unknown level error, got %v", err)
+	}
+
+	// Numbers still convert directly into numeric enums
+	result, err := ApplyChanges(Endpoint{}, []compare.Change{
+		{Field: "Level", ChangeType: compare.Modified, NewValue: float64(1)},
+	})
+	if err != nil || result.(Endpoint).Level != Info {
+		t.Errorf("Expected level %d, got %v (%v)", Info, result, err)
+	}
+}
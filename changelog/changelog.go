@@ -0,0 +1,330 @@
+package changelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/change"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrCorrupt is returned when a record in the log fails its checksum
+var ErrCorrupt = errors.New("changelog: corrupt record")
+
+// Options configures a Log
+type Options struct {
+	// SnapshotEvery writes a snapshot of the state passed to Append every n versions, 0 disables it
+	SnapshotEvery uint64
+
+	// OnSnapshotError is called when a snapshot taken by Append fails. The change set is committed by
+	// then, so Append still succeeds and replays fall back to the previous snapshot.
+	OnSnapshotError func(version uint64, err error)
+}
+
+// Log is an append-only, file backed log of change sets with periodic snapshots of the full struct.
+//
+// Every record is written as one line prefixed with its CRC-32 checksum and synced to disk before
+// Append returns. Any historical version can be rebuilt with Replay or ReplayAt.
+type Log struct {
+	mu         sync.Mutex
+	file       *os.File
+	options    Options
+	changeSets []compare.ChangeSet
+	snapshots  []snapshot
+}
+
+// snapshot is the full state of the struct at a version
+type snapshot struct {
+	Version   uint64          `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	State     json.RawMessage `json:"state"`
+}
+
+// record is a single line in the log file
+type record struct {
+	ChangeSet *compare.ChangeSet `json:"change_set,omitempty"`
+	Snapshot  *snapshot          `json:"snapshot,omitempty"`
+}
+
+// Open opens the log at path, creating it if needed, and loads its records.
+// A trailing record cut short by a crash is discarded; any other damaged record fails with ErrCorrupt.
+func Open(path string, options Options) (*Log, error) {
+	_, err := os.Stat(path)
+	created := errors.Is(err, os.ErrNotExist)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new file only survives a crash once its directory entry is on disk
+	if created {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	l := &Log{file: file, options: options}
+	if err := l.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the underlying file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Version returns the version of the latest change set in the log, 0 when it is empty
+func (l *Log) Version() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.version()
+}
+
+// ChangeSets returns the change sets recorded after version since, oldest first
+func (l *Log) ChangeSets(since uint64) []compare.ChangeSet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]compare.ChangeSet, 0)
+	for _, cs := range l.changeSets {
+		if cs.Version > since {
+			result = append(result, cs)
+		}
+	}
+	return result
+}
+
+// Append persists cs, which must continue from the current version of the log.
+// state is the struct after cs was applied; it is snapshotted when Options.SnapshotEvery says so
+// and may be nil to skip snapshotting. Append only fails when cs was not committed, a failed
+// snapshot is reported to Options.OnSnapshotError instead.
+func (l *Log) Append(cs compare.ChangeSet, state interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cs.ParentVersion != l.version() {
+		return fmt.Errorf("change set %s has parent version %d, log is at version %d", cs.ID, cs.ParentVersion, l.version())
+	}
+	if cs.Version <= cs.ParentVersion {
+		return fmt.Errorf("change set %s has version %d, must be greater than its parent version %d", cs.ID, cs.Version, cs.ParentVersion)
+	}
+
+	if err := l.write(record{ChangeSet: &cs}); err != nil {
+		return err
+	}
+	l.changeSets = append(l.changeSets, cs)
+
+	if state != nil && l.options.SnapshotEvery > 0 && cs.Version%l.options.SnapshotEvery == 0 {
+		if err := l.snapshot(cs.Version, state); err != nil && l.options.OnSnapshotError != nil {
+			l.options.OnSnapshotError(cs.Version, err)
+		}
+	}
+	return nil
+}
+
+// Snapshot persists the full state of the struct at the current version
+func (l *Log) Snapshot(state interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshot(l.version(), state)
+}
+
+// Replay rebuilds the struct as it was at version into target, which must be a pointer to a struct.
+// It starts from the latest snapshot at or before version (or the zero value) and applies the
+// change sets after it through change.ApplyChanges.
+func (l *Log) Replay(target interface{}, version uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if version > l.version() {
+		return fmt.Errorf("version %d not found, log is at version %d", version, l.version())
+	}
+	return l.replay(target, version)
+}
+
+// ReplayAt rebuilds the struct as it was at the given time into target, which must be a pointer to a struct
+func (l *Log) ReplayAt(target interface{}, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var version uint64
+	for _, cs := range l.changeSets {
+		if cs.Timestamp.After(at) {
+			break
+		}
+		version = cs.Version
+	}
+	return l.replay(target, version)
+}
+
+// version - returns the latest version, the caller must hold the lock
+func (l *Log) version() uint64 {
+	if len(l.changeSets) == 0 {
+		return 0
+	}
+	return l.changeSets[len(l.changeSets)-1].Version
+}
+
+// snapshot - persists state at version, the caller must hold the lock
+func (l *Log) snapshot(version uint64, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("cannot encode snapshot: %w", err)
+	}
+
+	snap := snapshot{Version: version, Timestamp: time.Now().UTC(), State: data}
+	if err := l.write(record{Snapshot: &snap}); err != nil {
+		return err
+	}
+	l.snapshots = append(l.snapshots, snap)
+	return nil
+}
+
+// replay - rebuilds the state at version into target, the caller must hold the lock
+func (l *Log) replay(target interface{}, version uint64) error {
+	targetVal := reflect.ValueOf(target)
+	if targetVal.Kind() != reflect.Ptr || targetVal.IsNil() || targetVal.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a non-nil pointer to a struct")
+	}
+	state := targetVal.Elem()
+	state.Set(reflect.Zero(state.Type()))
+
+	// Start from the latest snapshot that is not past the requested version
+	var from uint64
+	for i := len(l.snapshots) - 1; i >= 0; i-- {
+		if l.snapshots[i].Version <= version {
+			if err := json.Unmarshal(l.snapshots[i].State, target); err != nil {
+				return fmt.Errorf("cannot decode snapshot at version %d: %w", l.snapshots[i].Version, err)
+			}
+			from = l.snapshots[i].Version
+			break
+		}
+	}
+
+	for _, cs := range l.changeSets {
+		if cs.Version <= from || cs.Version > version {
+			continue
+		}
+
+		changes, err := compare.TypedChanges(state.Interface(), cs.Changes)
+		if err != nil {
+			return fmt.Errorf("change set %s: %w", cs.ID, err)
+		}
+
+		result, err := change.ApplyChanges(state.Interface(), changes)
+		if err != nil {
+			return fmt.Errorf("cannot replay change set %s: %w", cs.ID, err)
+		}
+		state.Set(reflect.ValueOf(result))
+	}
+	return nil
+}
+
+// write - appends a checksummed record and syncs it to disk, the caller must hold the lock
+func (l *Log) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("cannot encode record: %w", err)
+	}
+
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+	if _, err = l.file.WriteString(line); err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		// Cut off a partly written record so the next one does not follow a corrupt line
+		l.file.Truncate(offset)
+		l.file.Seek(offset, io.SeekStart)
+		return err
+	}
+	return nil
+}
+
+// syncDir - flushes the directory entries of dir to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// load - reads and verifies every record in the file, truncating a torn trailing record
+func (l *Log) load() error {
+	reader := bufio.NewReader(l.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// Torn write from a crash, drop it so the next append starts on a clean line
+				if err := l.file.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		r, err := decodeRecord(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			return fmt.Errorf("%w at offset %d: %v", ErrCorrupt, offset, err)
+		}
+		if r.ChangeSet != nil {
+			l.changeSets = append(l.changeSets, *r.ChangeSet)
+		}
+		if r.Snapshot != nil {
+			l.snapshots = append(l.snapshots, *r.Snapshot)
+		}
+		offset += int64(len(line))
+	}
+
+	_, err := l.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// decodeRecord - verifies the checksum of a line and decodes the record it holds
+func decodeRecord(line []byte) (record, error) {
+	var r record
+
+	checksum, data, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return r, fmt.Errorf("missing checksum")
+	}
+
+	var expected uint32
+	if _, err := fmt.Sscanf(string(checksum), "%08x", &expected); err != nil {
+		return r, fmt.Errorf("invalid checksum: %v", err)
+	}
+	if crc32.ChecksumIEEE(data) != expected {
+		return r, fmt.Errorf("checksum mismatch")
+	}
+
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
@@ -0,0 +1,224 @@
+package changelog
+
+import (
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type Document struct {
+	Title string
+	Pages int
+	Tags  []string
+}
+
+// appendVersion appends the diff between current and next as the next version of the log
+func appendVersion(t *testing.T, l *Log, current, next Document, at time.Time) {
+	t.Helper()
+
+	changes, err := compare.CompareStructs(current, next)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+	cs, err := compare.NewChangeSet(next, changes)
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+	cs.ParentVersion = l.Version()
+	cs.Version = cs.ParentVersion + 1
+	cs.Timestamp = at
+
+	if err := l.Append(cs, next); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+}
+
+func history() []Document {
+	return []Document{
+		{Title: "Draft", Pages: 1},
+		{Title: "Draft", Pages: 2, Tags: []string{"wip"}},
+		{Title: "Final", Pages: 3, Tags: []string{"wip"}},
+		{Title: "Final", Pages: 4, Tags: []string{"done", "print"}},
+	}
+}
+
+func TestReplayRebuildsEveryVersion(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "doc.log"), Options{SnapshotEvery: 2})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	versions := history()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := Document{}
+	for i, next := range versions {
+		appendVersion(t, l, current, next, start.Add(time.Duration(i)*time.Hour))
+		current = next
+	}
+
+	if l.Version() != 4 {
+		t.Fatalf("Expected version 4, got %d", l.Version())
+	}
+
+	for i, expected := range versions {
+		var doc Document
+		if err := l.Replay(&doc, uint64(i+1)); err != nil {
+			t.Fatalf("Replay of version %d failed: %v", i+1, err)
+		}
+		if doc.Title != expected.Title || doc.Pages != expected.Pages || len(doc.Tags) != len(expected.Tags) {
+			t.Errorf("Version %d: expected %+v, got %+v", i+1, expected, doc)
+		}
+	}
+
+	var empty Document
+	if err := l.Replay(&empty, 0); err != nil || empty.Title != "" {
+		t.Errorf("Expected zero value at version 0, got %+v (%v)", empty, err)
+	}
+	if err := l.Replay(&empty, 5); err == nil {
+		t.Error("Expected error when replaying a version that does not exist")
+	}
+}
+
+func TestReplayAtRebuildsStateAtTime(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "doc.log"), Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := Document{}
+	for i, next := range history() {
+		appendVersion(t, l, current, next, start.AddDate(0, 0, i))
+		current = next
+	}
+
+	var doc Document
+	if err := l.ReplayAt(&doc, start.AddDate(0, 0, 1).Add(time.Hour)); err != nil {
+		t.Fatalf("ReplayAt failed: %v", err)
+	}
+	if doc.Pages != 2 {
+		t.Errorf("Expected the state as of day two, got %+v", doc)
+	}
+}
+
+func TestOpenReloadsPersistedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.log")
+
+	l, err := Open(path, Options{SnapshotEvery: 3})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	current := Document{}
+	for _, next := range history() {
+		appendVersion(t, l, current, next, time.Now())
+		current = next
+	}
+	l.Close()
+
+	reopened, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Version() != 4 || len(reopened.ChangeSets(2)) != 2 {
+		t.Errorf("Expected 4 change sets to be reloaded, got version %d", reopened.Version())
+	}
+
+	var doc Document
+	if err := reopened.Replay(&doc, 4); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if doc.Title != "Final" || doc.Pages != 4 || len(doc.Tags) != 2 || doc.Tags[1] != "print" {
+		t.Errorf("Unexpected replayed state %+v", doc)
+	}
+}
+
+func TestAppendRejectsBrokenVersionChain(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "doc.log"), Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	if err := l.Append(compare.ChangeSet{ID: "a", ParentVersion: 3, Version: 4}, nil); err == nil {
+		t.Error("Expected error for a change set that does not continue the log")
+	}
+	if err := l.Append(compare.ChangeSet{ID: "b", ParentVersion: 0, Version: 0}, nil); err == nil {
+		t.Error("Expected error for a change set that does not advance the version")
+	}
+}
+
+func TestOpenDiscardsTornTrailingRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.log")
+
+	l, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	appendVersion(t, l, Document{}, Document{Title: "A"}, time.Now())
+	l.Close()
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`1234abcd {"change_set":{"id":"tor`)
+	file.Close()
+
+	reopened, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open with torn record failed: %v", err)
+	}
+	appendVersion(t, reopened, Document{Title: "A"}, Document{Title: "B"}, time.Now())
+	reopened.Close()
+
+	again, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open after recovery failed: %v", err)
+	}
+	defer again.Close()
+	if again.Version() != 2 {
+		t.Errorf("Expected version 2 after recovering from a torn record, got %d", again.Version())
+	}
+}
+
+func TestOpenFailsOnChecksumMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.log")
+	os.WriteFile(path, []byte("00000000 {\"change_set\":{\"id\":\"x\"}}\n"), 0o644)
+
+	_, err := Open(path, Options{})
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestAppendSucceedsWhenSnapshotFails(t *testing.T) {
+	var failed uint64
+	l, err := Open(filepath.Join(t.TempDir(), "doc.log"), Options{
+		SnapshotEvery:   1,
+		OnSnapshotError: func(version uint64, err error) { failed = version },
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	cs := compare.ChangeSet{ID: "a", ParentVersion: 0, Version: 1}
+	if err := l.Append(cs, map[string]interface{}{"unencodable": func() {}}); err != nil {
+		t.Fatalf("Expected committed change set to succeed, got %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("Expected snapshot failure at version 1 to be reported, got %d", failed)
+	}
+	if l.Version() != 1 {
+		t.Errorf("Expected log at version 1, got %d", l.Version())
+	}
+
+	// The next version continues from the committed one
+	if err := l.Append(compare.ChangeSet{ID: "b", ParentVersion: 1, Version: 2}, nil); err != nil {
+		t.Errorf("Append failed: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package compare
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ChangeSet wraps a list of changes with the metadata needed to audit or replay it:
+// which struct type and schema it applies to, which version it produces and who made it
+type ChangeSet struct {
+	ID            string    `json:"id"`
+	Version       uint64    `json:"version"`
+	ParentVersion uint64    `json:"parent_version"`
+	TypeName      string    `json:"type_name"`
+	SchemaHash    string    `json:"schema_hash"`
+	Author        string    `json:"author,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	Changes       []Change  `json:"changes"`
+
+	// Replica and Clock carry the origin replica and its vector clock for causal ordering
+	Replica string            `json:"replica,omitempty"`
+	Clock   map[string]uint64 `json:"clock,omitempty"`
+}
+
+// NewChangeSet creates a change set for changes made to target, which may be a struct or a pointer to one.
+// The ID, type name, schema hash and timestamp are filled in; versions and author are left to the caller.
+func NewChangeSet(target interface{}, changes []Change) (ChangeSet, error) {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	id, err := newChangeSetID()
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	return ChangeSet{
+		ID:         id,
+		TypeName:   typeNameOf(structType),
+		SchemaHash: schemaHashOf(structType),
+		Timestamp:  time.Now().UTC(),
+		Changes:    changes,
+	}, nil
+}
+
+// AppliesTo - checks that target has the type and schema the change set was recorded against. Change
+// sets recorded against an older schema pass once migrated, see ChangeSet.Migrate.
+func (cs ChangeSet) AppliesTo(target interface{}) error {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return err
+	}
+
+	if name := typeNameOf(structType); name != cs.TypeName {
+		return fmt.Errorf("change set %s applies to %s, not %s", cs.ID, cs.TypeName, name)
+	}
+	if hash := schemaHashOf(structType); hash != cs.SchemaHash {
+		return fmt.Errorf("change set %s was recorded against a different schema of %s", cs.ID, cs.TypeName)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a change set, restoring its changes the same way ChangesFromJSON does and
+// migrating them when it was recorded against a schema other than the current one of its type
+func (cs *ChangeSet) UnmarshalJSON(data []byte) error {
+	type plain ChangeSet
+	var raw struct {
+		plain
+		Changes json.RawMessage `json:"changes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*cs = ChangeSet(raw.plain)
+	cs.Changes = nil
+	if len(raw.Changes) == 0 || string(raw.Changes) == "null" {
+		return nil
+	}
+
+	changes, err := ChangesFromJSON(raw.Changes)
+	if err != nil {
+		return err
+	}
+	cs.Changes = changes
+
+	migrated, err := cs.Migrate()
+	if err != nil {
+		return err
+	}
+	*cs = migrated
+	return nil
+}
+
+// ChangeSetToJSON - serializes a change set to JSON
+func ChangeSetToJSON(cs ChangeSet) ([]byte, error) {
+	return json.Marshal(cs)
+}
+
+// ChangeSetFromJSON - deserializes a change set from JSON
+func ChangeSetFromJSON(data []byte) (ChangeSet, error) {
+	var cs ChangeSet
+	err := json.Unmarshal(data, &cs)
+	return cs, err
+}
+
+// SchemaHash - returns a hash of the exported field names and types of a struct,
+// which changes whenever a field is added, removed, renamed or retyped
+func SchemaHash(target interface{}) (string, error) {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return "", err
+	}
+	return schemaHashOf(structType), nil
+}
+
+// structTypeOf - returns the struct type of a struct or pointer to struct
+func structTypeOf(target interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(target)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("target must be a struct")
+	}
+	return t, nil
+}
+
+// schemaHashOf - hashes the exported fields of a struct type
+func schemaHashOf(structType reflect.Type) string {
+	var schema strings.Builder
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fmt.Fprintf(&schema, "%s %s;", field.Name, typeNameOf(field.Type))
+	}
+
+	sum := sha256.Sum256([]byte(schema.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// newChangeSetID - generates a random identifier for a change set
+func newChangeSetID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("cannot generate change set id: %w", err)
+	}
+	return hex.EncodeToString(id[:]), nil
+}
@@ -0,0 +1,115 @@
+package compare
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewChangeSetFillsMetadata(t *testing.T) {
+	changes := []Change{{Field: "Name", ChangeType: Modified, OldValue: "John", NewValue: "Jane"}}
+
+	cs, err := NewChangeSet(&Person{}, changes)
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+
+	if cs.ID == "" || cs.Timestamp.IsZero() || cs.SchemaHash == "" {
+		t.Errorf("Expected ID, timestamp and schema hash to be set, got %+v", cs)
+	}
+	if !strings.HasSuffix(cs.TypeName, "compare.Person") {
+		t.Errorf("Unexpected type name %q", cs.TypeName)
+	}
+	if len(cs.Changes) != 1 {
+		t.Errorf("Expected changes to be carried over")
+	}
+
+	other, _ := NewChangeSet(Person{}, nil)
+	if other.ID == cs.ID {
+		t.Errorf("Expected unique change set IDs")
+	}
+}
+
+func TestNewChangeSetFailsOnNonStruct(t *testing.T) {
+	if _, err := NewChangeSet("not a struct", nil); err == nil {
+		t.Error("Expected error for a non-struct target")
+	}
+}
+
+func TestChangeSetJSONRoundTrip(t *testing.T) {
+	RegisterType(&SMSNotifier{})
+
+	cs, err := NewChangeSet(Subscription{}, []Change{
+		{Field: "Topic", ChangeType: Modified, OldValue: "a", NewValue: "b"},
+		{Field: "Notifier", ChangeType: TypeChanged, NewValue: &SMSNotifier{Number: "555"},
+			OldType: TypeName(&EmailNotifier{}), NewType: TypeName(&SMSNotifier{})},
+	})
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+	cs.Version, cs.ParentVersion, cs.Author = 2, 1, "jane"
+
+	jsonData, err := ChangeSetToJSON(cs)
+	if err != nil {
+		t.Fatalf("ChangeSetToJSON failed: %v", err)
+	}
+
+	restored, err := ChangeSetFromJSON(jsonData)
+	if err != nil {
+		t.Fatalf("ChangeSetFromJSON failed: %v", err)
+	}
+
+	if restored.ID != cs.ID || restored.Version != 2 || restored.ParentVersion != 1 || restored.Author != "jane" ||
+		restored.TypeName != cs.TypeName || restored.SchemaHash != cs.SchemaHash || !restored.Timestamp.Equal(cs.Timestamp) {
+		t.Errorf("Metadata not preserved: %+v", restored)
+	}
+	if len(restored.Changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(restored.Changes))
+	}
+	if _, ok := findChangeByField(restored.Changes, "Notifier").NewValue.(*SMSNotifier); !ok {
+		t.Errorf("Expected registered type to be restored inside the change set")
+	}
+}
+
+func TestChangeSetAppliesTo(t *testing.T) {
+	type PersonV2 struct {
+		Name string
+	}
+
+	cs, err := NewChangeSet(Person{}, nil)
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+
+	if err := cs.AppliesTo(&Person{}); err != nil {
+		t.Errorf("Expected change set to apply to its own type: %v", err)
+	}
+	if err := cs.AppliesTo(PersonV2{}); err == nil {
+		t.Error("Expected error for a different type")
+	}
+
+	cs.SchemaHash = "stale"
+	if err := cs.AppliesTo(Person{}); err == nil {
+		t.Error("Expected error for a different schema")
+	}
+}
+
+func TestSchemaHashTracksFieldChanges(t *testing.T) {
+	type V1 struct {
+		Name string
+		age  int
+	}
+	type V2 struct {
+		Name string
+		Age  int
+	}
+
+	h1, _ := SchemaHash(V1{})
+	h2, _ := SchemaHash(V2{})
+	h3, _ := SchemaHash(&V1{})
+	if h1 == h2 {
+		t.Errorf("Expected schema hash to change when a field is added")
+	}
+	if h1 != h3 {
+		t.Errorf("Expected pointer and value to hash the same")
+	}
+}
@@ -0,0 +1,120 @@
+package compare
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Classifier decides whether a field that differs between two structs was added, deleted or modified.
+// It is only consulted for fields whose values are not deeply equal.
+type Classifier interface {
+	Classify(field reflect.StructField, oldValue, newValue reflect.Value) ChangeType
+}
+
+// ClassifierFunc adapts an ordinary function to the Classifier interface
+type ClassifierFunc func(field reflect.StructField, oldValue, newValue reflect.Value) ChangeType
+
+// Classify calls f(field, oldValue, newValue)
+func (f ClassifierFunc) Classify(field reflect.StructField, oldValue, newValue reflect.Value) ChangeType {
+	return f(field, oldValue, newValue)
+}
+
+// DefaultClassifier treats nil pointers and interfaces, empty slices and maps and empty strings as absent.
+// Every other kind is reported as Modified. This is the classifier used by CompareStructs.
+var DefaultClassifier Classifier = ClassifierFunc(classifyDefault)
+
+// ZeroValueClassifier treats the zero value of any kind as absent, so a number going to 0 or a bool
+// going false is reported as Deleted. Empty slices and maps count as absent as well.
+var ZeroValueClassifier Classifier = ClassifierFunc(classifyZeroValue)
+
+// ModifiedClassifier reports every differing field as Modified
+var ModifiedClassifier Classifier = ClassifierFunc(func(reflect.StructField, reflect.Value, reflect.Value) ChangeType {
+	return Modified
+})
+
+// NullableTagClassifier returns a classifier that applies ZeroValueClassifier to fields tagged
+// `sync:"nullable"` and delegates all other fields to fallback
+func NullableTagClassifier(fallback Classifier) Classifier {
+	return ClassifierFunc(func(field reflect.StructField, oldValue, newValue reflect.Value) ChangeType {
+		if hasSyncOption(field, "nullable") {
+			return classifyZeroValue(field, oldValue, newValue)
+		}
+		return fallback.Classify(field, oldValue, newValue)
+	})
+}
+
+// classifyDefault - the Added/Deleted heuristic CompareStructs has always used
+func classifyDefault(_ reflect.StructField, oldField, newField reflect.Value) ChangeType {
+	changeType := Modified
+
+	// Detect deletion based on type
+	switch oldField.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !oldField.IsNil() && newField.IsNil() {
+			changeType = Deleted
+		} else if oldField.IsNil() && !newField.IsNil() {
+			changeType = Added
+		}
+	case reflect.Slice, reflect.Map:
+		if oldField.Len() > 0 && newField.Len() == 0 {
+			changeType = Deleted
+		} else if oldField.Len() == 0 && newField.Len() > 0 {
+			changeType = Added
+		}
+	case reflect.String:
+		if oldField.String() != "" && newField.String() == "" {
+			changeType = Deleted
+		} else if oldField.String() == "" && newField.String() != "" {
+			changeType = Added
+		}
+	}
+
+	return changeType
+}
+
+// classifyZeroValue - treats the zero value of every kind as absent
+func classifyZeroValue(_ reflect.StructField, oldField, newField reflect.Value) ChangeType {
+	oldAbsent := isAbsent(oldField)
+	newAbsent := isAbsent(newField)
+
+	switch {
+	case !oldAbsent && newAbsent:
+		return Deleted
+	case oldAbsent && !newAbsent:
+		return Added
+	default:
+		return Modified
+	}
+}
+
+// isAbsent - reports whether a value is the zero value of its type, counting empty slices and maps as zero
+func isAbsent(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// hasSyncOption - reports whether the field's `sync` tag contains the given comma separated option
+func hasSyncOption(field reflect.StructField, option string) bool {
+	_, ok := SyncOption(field, option)
+	return ok
+}
+
+// SyncOption - returns the value of a `sync` struct tag option written as "option" or "option=value",
+// and whether the option is present
+func SyncOption(field reflect.StructField, option string) (string, bool) {
+	tag, ok := field.Tag.Lookup("sync")
+	if !ok {
+		return "", false
+	}
+	for _, part := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == option {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Settings struct {
+	Name     string
+	Retries  int
+	Enabled  bool
+	Timeout  int `sync:"nullable"`
+	Verbose  bool
+	Tags     []string
+	Override *string
+}
+
+func TestCompareStructsWithDefaultClassifierKeepsNumbersModified(t *testing.T) {
+	changes, err := CompareStructsWith(Settings{Retries: 3}, Settings{Retries: 0}, DefaultClassifier)
+	if err != nil {
+		t.Fatalf("CompareStructsWith failed: %v", err)
+	}
+
+	retriesChange := findChangeByField(changes, "Retries")
+	if retriesChange == nil || retriesChange.ChangeType != Modified {
+		t.Errorf("Expected Retries to be Modified, got %+v", retriesChange)
+	}
+}
+
+func TestCompareStructsWithZeroValueClassifier(t *testing.T) {
+	old := Settings{Retries: 3, Enabled: true, Tags: []string{}}
+	new := Settings{Retries: 0, Enabled: false, Tags: []string{"a"}}
+
+	changes, err := CompareStructsWith(old, new, ZeroValueClassifier)
+	if err != nil {
+		t.Fatalf("CompareStructsWith failed: %v", err)
+	}
+
+	if c := findChangeByField(changes, "Retries"); c == nil || c.ChangeType != Deleted {
+		t.Errorf("Expected Retries to be Deleted, got %+v", c)
+	}
+	if c := findChangeByField(changes, "Enabled"); c == nil || c.ChangeType != Deleted {
+		t.Errorf("Expected Enabled to be Deleted, got %+v", c)
+	}
+	if c := findChangeByField(changes, "Tags"); c == nil || c.ChangeType != Added {
+		t.Errorf("Expected Tags to be Added, got %+v", c)
+	}
+}
+
+func TestCompareStructsWithNullableTagClassifier(t *testing.T) {
+	old := Settings{Timeout: 30, Verbose: true}
+	new := Settings{Timeout: 0, Verbose: false}
+
+	changes, err := CompareStructsWith(old, new, NullableTagClassifier(DefaultClassifier))
+	if err != nil {
+		t.Fatalf("CompareStructsWith failed: %v", err)
+	}
+
+	if c := findChangeByField(changes, "Timeout"); c == nil || c.ChangeType != Deleted {
+		t.Errorf("Expected tagged Timeout to be Deleted, got %+v", c)
+	}
+	if c := findChangeByField(changes, "Verbose"); c == nil || c.ChangeType != Modified {
+		t.Errorf("Expected untagged Verbose to fall back to Modified, got %+v", c)
+	}
+}
+
+func TestCompareStructsWithModifiedClassifier(t *testing.T) {
+	override := "x"
+	old := Settings{Name: "a", Override: &override}
+	new := Settings{Name: "", Tags: []string{"a"}}
+
+	changes, err := CompareStructsWith(old, new, ModifiedClassifier)
+	if err != nil {
+		t.Fatalf("CompareStructsWith failed: %v", err)
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d", len(changes))
+	}
+	for _, c := range changes {
+		if c.ChangeType != Modified {
+			t.Errorf("Expected every change to be Modified, got %+v", c)
+		}
+	}
+}
+
+func TestCompareStructsWithCustomClassifierFunc(t *testing.T) {
+	classifier := ClassifierFunc(func(field reflect.StructField, oldValue, newValue reflect.Value) ChangeType {
+		if field.Name == "Name" {
+			return Added
+		}
+		return DefaultClassifier.Classify(field, oldValue, newValue)
+	})
+
+	changes, err := CompareStructsWith(Settings{Name: "a"}, Settings{Name: "b"}, classifier)
+	if err != nil {
+		t.Fatalf("CompareStructsWith failed: %v", err)
+	}
+
+	if c := findChangeByField(changes, "Name"); c == nil || c.ChangeType != Added {
+		t.Errorf("Custom classifier was not consulted, got %+v", c)
+	}
+}
+
+func TestCompareStructsDeepWithUsesClassifierForNestedFields(t *testing.T) {
+	old := Team{Lead: Person{Age: 40}}
+	new := Team{Lead: Person{Age: 0}}
+
+	changes, err := CompareStructsDeepWith(old, new, ZeroValueClassifier)
+	if err != nil {
+		t.Fatalf("CompareStructsDeepWith failed: %v", err)
+	}
+
+	if c := findChangeByField(changes, "Lead.Age"); c == nil || c.ChangeType != Deleted {
+		t.Errorf("Expected Lead.Age to be Deleted, got %+v", c)
+	}
+}
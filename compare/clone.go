@@ -0,0 +1,84 @@
+package compare
+
+import "reflect"
+
+// clonedPointer identifies a pointer already copied by cloneValue
+type clonedPointer struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// Clone returns a deep copy of value that shares no pointers, maps or slices with it, so the copy can be
+// mutated in place and still be compared against the original. Shared and cyclic pointers are copied
+// once, keeping their structure. Unexported struct fields cannot be reached through reflection and are
+// copied shallowly, and channels and functions are shared.
+func Clone[T any](value T) T {
+	var clone T
+	reflect.ValueOf(&clone).Elem().Set(cloneOf(reflect.ValueOf(&value).Elem()))
+	return clone
+}
+
+// cloneOf - returns a deep copy of v
+func cloneOf(v reflect.Value) reflect.Value {
+	return cloneValue(v, make(map[clonedPointer]reflect.Value))
+}
+
+// cloneValue - returns a deep copy of v, reusing the copies of pointers in visited
+func cloneValue(v reflect.Value, visited map[clonedPointer]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := clonedPointer{ptr: v.Pointer(), typ: v.Type()}
+		if clone, ok := visited[key]; ok {
+			return clone
+		}
+		clone := reflect.New(v.Type().Elem())
+		visited[key] = clone
+		clone.Elem().Set(cloneValue(v.Elem(), visited))
+		return clone
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(cloneValue(v.Elem(), visited))
+		return clone
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), cloneValue(iter.Value(), visited))
+		}
+		return clone
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return clone
+	case reflect.Array:
+		clone := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				clone.Field(i).Set(cloneValue(v.Field(i), visited))
+			}
+		}
+		return clone
+	}
+	return v
+}
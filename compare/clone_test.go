@@ -0,0 +1,24 @@
+package compare
+
+import "testing"
+
+func TestCloneSharesNothingWithTheOriginal(t *testing.T) {
+	original := &Person{Name: "John", Children: []string{"a"}, Manager: &Person{Name: "Boss"}}
+	original.Manager.Manager = original
+
+	clone := Clone(original)
+	clone.Children[0] = "b"
+	clone.Manager.Name = "Chief"
+
+	if original.Children[0] != "a" || original.Manager.Name != "Boss" {
+		t.Errorf("Expected the original to be unchanged, got %+v", original)
+	}
+	if clone.Manager.Manager != clone {
+		t.Error("Expected the cycle to point back to the clone")
+	}
+
+	var empty interface{}
+	if Clone(empty) != nil {
+		t.Error("Expected a nil interface to clone to nil")
+	}
+}
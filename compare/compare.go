@@ -1,6 +1,7 @@
 package compare
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -11,9 +12,10 @@ import (
 type ChangeType string
 
 const (
-	Modified ChangeType = "modified"
-	Deleted  ChangeType = "deleted"
-	Added    ChangeType = "added"
+	Modified    ChangeType = "modified"
+	Deleted     ChangeType = "deleted"
+	Added       ChangeType = "added"
+	TypeChanged ChangeType = "type_changed"
 )
 
 // Change represents a difference between two struct fields
@@ -22,10 +24,20 @@ type Change struct {
 	ChangeType ChangeType
 	OldValue   interface{}
 	NewValue   interface{}
+
+	// OldType and NewType hold the dynamic type names of an interface field for TypeChanged changes
+	OldType string `json:",omitempty"`
+	NewType string `json:",omitempty"`
 }
 
 // CompareStructs compares two struct instances and returns a list of changes
 func CompareStructs(old, new interface{}) ([]Change, error) {
+	return CompareStructsWith(old, new, DefaultClassifier)
+}
+
+// CompareStructsWith compares two struct instances like CompareStructs, using classifier
+// to decide whether a differing field was added, deleted or modified
+func CompareStructsWith(old, new interface{}, classifier Classifier) ([]Change, error) {
 	oldVal := reflect.ValueOf(old)
 	newVal := reflect.ValueOf(new)
 
@@ -51,16 +63,16 @@ func CompareStructs(old, new interface{}) ([]Change, error) {
 
 	// Cache field information
 	type fieldInfo struct {
-		oldField reflect.Value
-		newField reflect.Value
-		name     string
+		oldField    reflect.Value
+		newField    reflect.Value
+		structField reflect.StructField
 	}
 	fields := make([]fieldInfo, oldVal.NumField())
 	for i := 0; i < oldVal.NumField(); i++ {
 		fields[i] = fieldInfo{
-			oldField: oldVal.Field(i),
-			newField: newVal.Field(i),
-			name:     oldVal.Type().Field(i).Name,
+			oldField:    oldVal.Field(i),
+			newField:    newVal.Field(i),
+			structField: oldVal.Type().Field(i),
 		}
 	}
 
@@ -77,37 +89,10 @@ func CompareStructs(old, new interface{}) ([]Change, error) {
 
 			// Compare values
 			if !reflect.DeepEqual(field.oldField.Interface(), field.newField.Interface()) {
-				changeType := Modified
-
-				// Detect deletion based on type
-				switch field.oldField.Kind() {
-				case reflect.Ptr, reflect.Interface:
-					if !field.oldField.IsNil() && field.newField.IsNil() {
-						changeType = Deleted
-					} else if field.oldField.IsNil() && !field.newField.IsNil() {
-						changeType = Added
-					}
-				case reflect.Slice, reflect.Map:
-					if field.oldField.Len() > 0 && field.newField.Len() == 0 {
-						changeType = Deleted
-					} else if field.oldField.Len() == 0 && field.newField.Len() > 0 {
-						changeType = Added
-					}
-				case reflect.String:
-					if field.oldField.String() != "" && field.newField.String() == "" {
-						changeType = Deleted
-					} else if field.oldField.String() == "" && field.newField.String() != "" {
-						changeType = Added
-					}
-				}
+				change := newChange(field.structField.Name, field.structField, field.oldField, field.newField, classifier)
 
 				mu.Lock()
-				changes = append(changes, Change{
-					Field:      field.name,
-					ChangeType: changeType,
-					OldValue:   field.oldField.Interface(),
-					NewValue:   field.newField.Interface(),
-				})
+				changes = append(changes, change)
 				mu.Unlock()
 			}
 		}(field)
@@ -117,6 +102,27 @@ func CompareStructs(old, new interface{}) ([]Change, error) {
 	return changes, nil
 }
 
+// newChange - builds the change for a differing field, recording dynamic types when they differ
+func newChange(path string, structField reflect.StructField, oldField, newField reflect.Value, classifier Classifier) Change {
+	change := Change{
+		Field:    path,
+		OldValue: oldField.Interface(),
+		NewValue: newField.Interface(),
+	}
+
+	// An interface holding a different concrete type is reported regardless of the classifier
+	if oldField.Kind() == reflect.Interface && !oldField.IsNil() && !newField.IsNil() &&
+		oldField.Elem().Type() != newField.Elem().Type() {
+		change.ChangeType = TypeChanged
+		change.OldType = TypeName(change.OldValue)
+		change.NewType = TypeName(change.NewValue)
+		return change
+	}
+
+	change.ChangeType = classifier.Classify(structField, oldField, newField)
+	return change
+}
+
 // FilterChanges - returns a subset of changes that match the provided criteria
 func FilterChanges(changes []Change, changeTypes []ChangeType, fields []string) []Change {
 	if len(changeTypes) == 0 && len(fields) == 0 {
@@ -162,6 +168,10 @@ func RevertChanges(changes []Change) []Change {
 			reverted[i].ChangeType = Added
 		case Modified:
 			reverted[i].ChangeType = Modified
+		case TypeChanged:
+			reverted[i].ChangeType = TypeChanged
+			reverted[i].OldType = change.NewType
+			reverted[i].NewType = change.OldType
 		}
 	}
 
@@ -206,6 +216,8 @@ func FormatChanges(changes []Change) string {
 			result += fmt.Sprintf("Added %s: %v\n", change.Field, change.NewValue)
 		case Deleted:
 			result += fmt.Sprintf("Deleted %s (was %v)\n", change.Field, change.OldValue)
+		case TypeChanged:
+			result += fmt.Sprintf("Changed type of %s: %s → %s\n", change.Field, change.OldType, change.NewType)
 		}
 	}
 
@@ -218,8 +230,38 @@ func ChangesToJSON(changes []Change) ([]byte, error) {
 }
 
 // ChangesFromJSON - deserializes a list of changes from JSON
+//
+// Values of TypeChanged changes are reconstructed as their concrete type when it was registered with RegisterType.
+// data may also hold a change set, whose changes are returned migrated to the current schema of its type.
 func ChangesFromJSON(data []byte) ([]Change, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var cs ChangeSet
+		if err := json.Unmarshal(data, &cs); err != nil {
+			return nil, err
+		}
+		return cs.Changes, nil
+	}
+
 	var changes []Change
-	err := json.Unmarshal(data, &changes)
-	return changes, err
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return changes, err
+	}
+
+	for i := range changes {
+		if changes[i].ChangeType != TypeChanged {
+			continue
+		}
+
+		oldValue, err := restoreRegistered(changes[i].OldType, changes[i].OldValue)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", changes[i].Field, err)
+		}
+		newValue, err := restoreRegistered(changes[i].NewType, changes[i].NewValue)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", changes[i].Field, err)
+		}
+		changes[i].OldValue = oldValue
+		changes[i].NewValue = newValue
+	}
+	return changes, nil
 }
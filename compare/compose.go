@@ -0,0 +1,184 @@
+package compare
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Compose - combines two consecutive change lists into one equivalent list, where b was applied after a
+func Compose(a, b []Change) []Change {
+	return Squash(a, b)
+}
+
+// Squash - reduces a history of consecutive change lists to a minimal equivalent change list.
+//
+// Unlike MergeChanges, each resulting change keeps the OldValue of the first change to its field and the
+// NewValue of the last one, and its ChangeType describes the net effect: a field that was added and later
+// deleted disappears, a deleted and re-added field becomes Modified, and a field that ends up back at its
+// original value is dropped. Fields keep the order in which they first appear.
+//
+// A change to a nested struct replaces everything below it, so earlier changes to its dotted child paths
+// are dropped, with their original values folded into its OldValue. Later changes to child paths stay
+// ordered behind it, and applying the result has the same effect as applying the lists in sequence.
+func Squash(changeLists ...[]Change) []Change {
+	squashed := make(map[string]*Change)
+	order := make([]string, 0)
+
+	// Fields whose OldValue could not be restored from the children they replaced
+	pinned := make(map[string]bool)
+
+	for _, list := range changeLists {
+		for _, change := range list {
+			previous, ok := squashed[change.Field]
+			if !ok {
+				copied := change
+				order = absorbChildren(change.Field, &copied, order, squashed, pinned)
+				squashed[change.Field] = &copied
+				order = append(order, change.Field)
+				continue
+			}
+
+			// Children changed since the previous change, which already recorded the original value
+			order = absorbChildren(change.Field, nil, order, squashed, pinned)
+
+			composed := composeChange(previous, change)
+			if composed == nil && pinned[change.Field] && previous != nil && previous.ChangeType != Added {
+				// The recorded OldValue is not the original, so equal values do not mean nothing changed
+				composed = &Change{Field: change.Field, ChangeType: Modified, OldValue: previous.OldValue, NewValue: change.NewValue}
+			}
+			squashed[change.Field] = composed
+		}
+	}
+
+	result := make([]Change, 0, len(order))
+	for _, field := range order {
+		if change := squashed[field]; change != nil {
+			result = append(result, *change)
+		}
+	}
+	return result
+}
+
+// absorbChildren - removes the entries for dotted child paths of field from order and squashed. When first
+// is the field's first change, the original values of the children are folded into its OldValue, latest
+// first so the earliest recorded value of overlapping paths wins.
+func absorbChildren(field string, first *Change, order []string, squashed map[string]*Change, pinned map[string]bool) []string {
+	prefix := field + "."
+	kept := order[:0]
+	children := make([]*Change, 0)
+	for _, f := range order {
+		if strings.HasPrefix(f, prefix) {
+			if squashed[f] != nil {
+				children = append(children, squashed[f])
+			}
+			delete(squashed, f)
+			delete(pinned, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if first != nil {
+		for i := len(children) - 1; i >= 0; i-- {
+			oldValue, ok := withPath(first.OldValue, strings.TrimPrefix(children[i].Field, prefix), children[i].OldValue)
+			if !ok {
+				pinned[field] = true
+				continue
+			}
+			first.OldValue = oldValue
+		}
+	}
+	return kept
+}
+
+// withPath - returns a copy of value with the field at the dotted path set to fieldValue, and false when
+// the path cannot be reached
+func withPath(value interface{}, path string, fieldValue interface{}) (interface{}, bool) {
+	root := reflect.ValueOf(value)
+	if !root.IsValid() {
+		return nil, false
+	}
+
+	copy := reflect.New(root.Type()).Elem()
+	copy.Set(cloneOf(root))
+
+	field := copy
+	for _, name := range strings.Split(path, ".") {
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				return nil, false
+			}
+			field = field.Elem()
+		}
+		if field.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field = field.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			return nil, false
+		}
+	}
+
+	if fieldValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return copy.Interface(), true
+	}
+	newValue := reflect.ValueOf(fieldValue)
+	if !newValue.Type().AssignableTo(field.Type()) {
+		return nil, false
+	}
+	field.Set(newValue)
+	return copy.Interface(), true
+}
+
+// composeChange - folds a later change into the net change recorded so far, returning nil when they cancel out
+func composeChange(previous *Change, next Change) *Change {
+	// An earlier cancellation leaves nothing to compose with, the field starts over
+	if previous == nil {
+		copied := next
+		return &copied
+	}
+
+	composed := Change{
+		Field:    next.Field,
+		OldValue: previous.OldValue,
+		NewValue: next.NewValue,
+		OldType:  previous.OldType,
+		NewType:  next.NewType,
+	}
+
+	switch {
+	case previous.ChangeType == Added && next.ChangeType == Deleted:
+		return nil
+	case previous.ChangeType == Added:
+		composed.ChangeType = Added
+	case next.ChangeType == Deleted:
+		composed.ChangeType = Deleted
+	default:
+		composed.ChangeType = Modified
+	}
+
+	// Work out whether the dynamic type still differs end to end
+	if previous.ChangeType == TypeChanged || next.ChangeType == TypeChanged {
+		oldType := previous.OldType
+		if previous.ChangeType != TypeChanged {
+			oldType = TypeName(previous.OldValue)
+		}
+		newType := next.NewType
+		if next.ChangeType != TypeChanged {
+			newType = TypeName(next.NewValue)
+		}
+
+		composed.OldType, composed.NewType = "", ""
+		if composed.ChangeType == Modified && oldType != newType {
+			composed.ChangeType = TypeChanged
+			composed.OldType, composed.NewType = oldType, newType
+		}
+	}
+
+	// Back where it started, nothing to apply
+	if composed.ChangeType == Modified && reflect.DeepEqual(composed.OldValue, composed.NewValue) {
+		return nil
+	}
+	return &composed
+}
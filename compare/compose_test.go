@@ -0,0 +1,174 @@
+package compare
+
+import "testing"
+
+func TestSquashKeepsFirstOldValueAndLastNewValue(t *testing.T) {
+	squashed := Squash(
+		[]Change{{Field: "Age", ChangeType: Modified, OldValue: 30, NewValue: 31}},
+		[]Change{{Field: "Age", ChangeType: Modified, OldValue: 31, NewValue: 32}},
+		[]Change{{Field: "Age", ChangeType: Modified, OldValue: 32, NewValue: 33}},
+	)
+
+	if len(squashed) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(squashed))
+	}
+	if squashed[0].ChangeType != Modified || squashed[0].OldValue.(int) != 30 || squashed[0].NewValue.(int) != 33 {
+		t.Errorf("Squashed change not composed correctly: %+v", squashed[0])
+	}
+}
+
+func TestSquashCancelsAddedThenDeleted(t *testing.T) {
+	squashed := Squash(
+		[]Change{{Field: "Address", ChangeType: Added, NewValue: "456 Oak St"}},
+		[]Change{{Field: "Address", ChangeType: Deleted, OldValue: "456 Oak St"}},
+	)
+
+	if len(squashed) != 0 {
+		t.Errorf("Expected Added then Deleted to cancel out, got %+v", squashed)
+	}
+}
+
+func TestSquashDropsChangesBackToOriginalValue(t *testing.T) {
+	squashed := Squash(
+		[]Change{{Field: "Name", ChangeType: Modified, OldValue: "John", NewValue: "Jane"}},
+		[]Change{{Field: "Name", ChangeType: Modified, OldValue: "Jane", NewValue: "John"}},
+	)
+
+	if len(squashed) != 0 {
+		t.Errorf("Expected round trip to the original value to be dropped, got %+v", squashed)
+	}
+}
+
+func TestSquashClassifiesNetEffect(t *testing.T) {
+	squashed := Squash(
+		[]Change{
+			{Field: "Address", ChangeType: Deleted, OldValue: "123 Main St"},
+			{Field: "Nickname", ChangeType: Added, NewValue: "JD"},
+			{Field: "Name", ChangeType: Modified, OldValue: "John", NewValue: "Jane"},
+		},
+		[]Change{
+			{Field: "Address", ChangeType: Added, NewValue: "456 Oak St"},
+			{Field: "Nickname", ChangeType: Modified, OldValue: "JD", NewValue: "Johnny"},
+			{Field: "Name", ChangeType: Deleted, OldValue: "Jane"},
+		},
+	)
+
+	if len(squashed) != 3 {
+		t.Fatalf("Expected 3 changes, got %d: %+v", len(squashed), squashed)
+	}
+	if squashed[0].Field != "Address" || squashed[1].Field != "Nickname" || squashed[2].Field != "Name" {
+		t.Errorf("Expected fields in order of first appearance, got %+v", squashed)
+	}
+
+	if c := squashed[0]; c.ChangeType != Modified || c.OldValue != "123 Main St" || c.NewValue != "456 Oak St" {
+		t.Errorf("Deleted then Added should become Modified, got %+v", c)
+	}
+	if c := squashed[1]; c.ChangeType != Added || c.OldValue != nil || c.NewValue != "Johnny" {
+		t.Errorf("Added then Modified should stay Added, got %+v", c)
+	}
+	if c := squashed[2]; c.ChangeType != Deleted || c.OldValue != "John" {
+		t.Errorf("Modified then Deleted should become Deleted, got %+v", c)
+	}
+}
+
+func TestSquashReAddsAfterCancellation(t *testing.T) {
+	squashed := Squash(
+		[]Change{{Field: "Address", ChangeType: Added, NewValue: "A"}},
+		[]Change{{Field: "Address", ChangeType: Deleted, OldValue: "A"}},
+		[]Change{{Field: "Address", ChangeType: Added, NewValue: "B"}},
+	)
+
+	if len(squashed) != 1 || squashed[0].ChangeType != Added || squashed[0].NewValue != "B" {
+		t.Errorf("Expected a single Added change, got %+v", squashed)
+	}
+}
+
+func TestComposeResolvesTypeChanges(t *testing.T) {
+	email := &EmailNotifier{Address: "a@example.com"}
+	sms := &SMSNotifier{Number: "555"}
+	otherEmail := &EmailNotifier{Address: "b@example.com"}
+
+	there := []Change{{Field: "Notifier", ChangeType: TypeChanged, OldValue: email, NewValue: sms,
+		OldType: TypeName(email), NewType: TypeName(sms)}}
+	back := []Change{{Field: "Notifier", ChangeType: TypeChanged, OldValue: sms, NewValue: otherEmail,
+		OldType: TypeName(sms), NewType: TypeName(otherEmail)}}
+
+	composed := Compose(there, back)
+	if len(composed) != 1 || composed[0].ChangeType != Modified || composed[0].OldType != "" {
+		t.Errorf("Expected a plain Modified change when the type ends up unchanged, got %+v", composed)
+	}
+
+	composed = Compose(there, []Change{{Field: "Notifier", ChangeType: Modified, OldValue: sms, NewValue: &SMSNotifier{Number: "666"}}})
+	if len(composed) != 1 || composed[0].ChangeType != TypeChanged || composed[0].NewType != TypeName(sms) {
+		t.Errorf("Expected the type change to survive, got %+v", composed)
+	}
+}
+
+func TestSquashFoldsChildChangesIntoLaterParentChange(t *testing.T) {
+	boss := &Person{Name: "Boss", Age: 50}
+	renamed := &Person{Name: "Chief", Age: 50}
+	replaced := &Person{Name: "New", Age: 40}
+
+	squashed := Squash(
+		[]Change{{Field: "Manager.Name", ChangeType: Modified, OldValue: "Boss", NewValue: "Chief"}},
+		[]Change{{Field: "Manager", ChangeType: Modified, OldValue: renamed, NewValue: replaced}},
+	)
+
+	if len(squashed) != 1 || squashed[0].Field != "Manager" {
+		t.Fatalf("Expected the parent change to replace its child, got %+v", squashed)
+	}
+	if old := squashed[0].OldValue.(*Person); old.Name != "Boss" || old.Age != 50 {
+		t.Errorf("Expected OldValue to be the original manager, got %+v", old)
+	}
+	if squashed[0].NewValue.(*Person) != replaced {
+		t.Errorf("Expected NewValue to be the replacement, got %+v", squashed[0].NewValue)
+	}
+	if renamed.Name != "Chief" || boss.Name != "Boss" {
+		t.Error("Expected recorded values to be left unchanged")
+	}
+}
+
+func TestSquashOrdersChildChangesAfterTheirParent(t *testing.T) {
+	replaced := &Person{Name: "New", Age: 40}
+
+	squashed := Squash(
+		[]Change{{Field: "Manager.Name", ChangeType: Modified, OldValue: "Boss", NewValue: "Chief"}},
+		[]Change{{Field: "Manager", ChangeType: Modified, OldValue: &Person{Name: "Chief"}, NewValue: replaced}},
+		[]Change{{Field: "Manager.Age", ChangeType: Modified, OldValue: 40, NewValue: 41}},
+	)
+
+	if len(squashed) != 2 || squashed[0].Field != "Manager" || squashed[1].Field != "Manager.Age" {
+		t.Fatalf("Expected Manager followed by Manager.Age, got %+v", squashed)
+	}
+}
+
+func TestSquashKeepsParentChangedBackToAnIntermediateValue(t *testing.T) {
+	renamed := Person{Name: "Chief"}
+
+	// The parent's own changes cancel out, but only against the value after the child change
+	squashed := Squash(
+		[]Change{{Field: "Lead.Name", ChangeType: Modified, OldValue: "Boss", NewValue: "Chief"}},
+		[]Change{{Field: "Lead", ChangeType: Modified, OldValue: renamed, NewValue: Person{Name: "Other"}}},
+		[]Change{{Field: "Lead", ChangeType: Modified, OldValue: Person{Name: "Other"}, NewValue: renamed}},
+	)
+
+	if len(squashed) != 1 || squashed[0].Field != "Lead" {
+		t.Fatalf("Expected a single Lead change, got %+v", squashed)
+	}
+	if squashed[0].OldValue.(Person).Name != "Boss" || squashed[0].NewValue.(Person).Name != "Chief" {
+		t.Errorf("Expected Lead to go from Boss to Chief, got %+v", squashed[0])
+	}
+}
+
+func TestSquashKeepsParentWhenChildrenCannotBeFolded(t *testing.T) {
+	// The child path ran through a nil pointer when the parent change was recorded
+	squashed := Squash(
+		[]Change{{Field: "Manager.Manager.Name", ChangeType: Added, NewValue: "Chief"}},
+		[]Change{{Field: "Manager", ChangeType: Modified, OldValue: &Person{Name: "Boss"}, NewValue: &Person{Name: "Other"}}},
+		[]Change{{Field: "Manager", ChangeType: Modified, OldValue: &Person{Name: "Other"}, NewValue: &Person{Name: "Boss"}}},
+	)
+
+	if len(squashed) != 1 || squashed[0].Field != "Manager" || squashed[0].NewValue.(*Person).Name != "Boss" {
+		t.Errorf("Expected the Manager change to be kept, got %+v", squashed)
+	}
+}
@@ -0,0 +1,122 @@
+package compare
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// visit identifies a pair of pointers that has already been walked by CompareStructsDeep
+type visit struct {
+	old uintptr
+	new uintptr
+	typ reflect.Type
+}
+
+// CompareStructsDeep compares two struct instances recursively and returns a list of changes.
+// Nested struct fields are descended into and reported by their dotted path (e.g. "Manager.Name").
+//
+// Pointer pairs are tracked while walking, so self-referential structures such as parent pointers
+// or cyclic linked lists terminate: a pair that has already been visited is treated as a reference
+// to the earlier comparison and is not walked again. Differences inside it are reported at the first
+// path through which the pair was reached, and a reference to a pair that differs is reported as a
+// single change holding both pointers.
+func CompareStructsDeep(old, new interface{}) ([]Change, error) {
+	return CompareStructsDeepWith(old, new, DefaultClassifier)
+}
+
+// CompareStructsDeepWith compares two struct instances like CompareStructsDeep, using classifier
+// to decide whether a differing field was added, deleted or modified
+func CompareStructsDeepWith(old, new interface{}, classifier Classifier) ([]Change, error) {
+	oldVal := reflect.ValueOf(old)
+	newVal := reflect.ValueOf(new)
+
+	// Dereference if pointers
+	if oldVal.Kind() == reflect.Ptr {
+		oldVal = oldVal.Elem()
+	}
+	if newVal.Kind() == reflect.Ptr {
+		newVal = newVal.Elem()
+	}
+
+	// Validate input types
+	if oldVal.Kind() != reflect.Struct || newVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("both arguments must be structs")
+	}
+	if oldVal.Type() != newVal.Type() {
+		return nil, fmt.Errorf("both structs must be of the same type")
+	}
+
+	// Mark the roots as visited when they were passed by pointer so back references to them stop
+	visited := make(map[visit]bool)
+	if rootOld, rootNew := reflect.ValueOf(old), reflect.ValueOf(new); rootOld.Kind() == reflect.Ptr && rootNew.Kind() == reflect.Ptr {
+		visited[visit{old: rootOld.Pointer(), new: rootNew.Pointer(), typ: rootOld.Type()}] = true
+	}
+
+	changes := make([]Change, 0, oldVal.NumField())
+	diffStruct("", oldVal, newVal, visited, classifier, &changes)
+	return changes, nil
+}
+
+// diffStruct - walks the exported fields of two struct values of the same type and collects changes
+func diffStruct(prefix string, oldVal, newVal reflect.Value, visited map[visit]bool, classifier Classifier, changes *[]Change) {
+	structType := oldVal.Type()
+
+	for i := 0; i < oldVal.NumField(); i++ {
+		oldField := oldVal.Field(i)
+		newField := newVal.Field(i)
+
+		// Skip unexported fields
+		if !oldField.CanInterface() {
+			continue
+		}
+
+		structField := structType.Field(i)
+		path := structField.Name
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		diffField(path, structField, oldField, newField, visited, classifier, changes)
+	}
+}
+
+// diffField - compares a single field, descending into nested structs and struct pointers
+func diffField(path string, structField reflect.StructField, oldField, newField reflect.Value, visited map[visit]bool, classifier Classifier, changes *[]Change) {
+	switch {
+	case oldField.Kind() == reflect.Struct && hasExportedFields(oldField.Type()):
+		diffStruct(path, oldField, newField, visited, classifier, changes)
+		return
+	case oldField.Kind() == reflect.Ptr && oldField.Type().Elem().Kind() == reflect.Struct &&
+		!oldField.IsNil() && !newField.IsNil() && hasExportedFields(oldField.Type().Elem()):
+		// Same object on both sides, nothing can differ
+		if oldField.Pointer() == newField.Pointer() {
+			return
+		}
+
+		// Already walked this pair, report the reference instead of descending again
+		key := visit{old: oldField.Pointer(), new: newField.Pointer(), typ: oldField.Type()}
+		if visited[key] {
+			if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+				*changes = append(*changes, newChange(path, structField, oldField, newField, classifier))
+			}
+			return
+		}
+		visited[key] = true
+
+		diffStruct(path, oldField.Elem(), newField.Elem(), visited, classifier, changes)
+		return
+	}
+
+	if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+		*changes = append(*changes, newChange(path, structField, oldField, newField, classifier))
+	}
+}
+
+// hasExportedFields - reports whether a struct type has any fields CompareStructsDeep can descend into
+func hasExportedFields(structType reflect.Type) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package compare
+
+import "testing"
+
+type Node struct {
+	Value  int
+	Next   *Node
+	Parent *Node
+}
+
+type Team struct {
+	Name string
+	Lead Person
+}
+
+func TestCompareStructsDeepReportsNestedPaths(t *testing.T) {
+	old := Person{Name: "John", Manager: &Person{Name: "Boss", Age: 50}}
+	new := Person{Name: "John", Manager: &Person{Name: "Boss", Age: 51}}
+
+	changes, err := CompareStructsDeep(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].Field != "Manager.Age" || changes[0].ChangeType != Modified || changes[0].NewValue.(int) != 51 {
+		t.Errorf("Nested change not detected correctly: %+v", changes[0])
+	}
+}
+
+func TestCompareStructsDeepDescendsIntoStructValues(t *testing.T) {
+	old := Team{Name: "Core", Lead: Person{Name: "John"}}
+	new := Team{Name: "Core", Lead: Person{Name: "Jane"}}
+
+	changes, err := CompareStructsDeep(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	if findChangeByField(changes, "Lead.Name") == nil {
+		t.Errorf("Expected change for Lead.Name, got %+v", changes)
+	}
+}
+
+func TestCompareStructsDeepKeepsPointerAdditionsAtParentPath(t *testing.T) {
+	old := Person{Name: "John"}
+	new := Person{Name: "John", Manager: &Person{Name: "Boss"}}
+
+	changes, err := CompareStructsDeep(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	managerChange := findChangeByField(changes, "Manager")
+	if managerChange == nil || managerChange.ChangeType != Added {
+		t.Errorf("Added Manager not detected correctly: %+v", changes)
+	}
+}
+
+func TestCompareStructsDeepTerminatesOnCyclicList(t *testing.T) {
+	oldA, oldB := &Node{Value: 1}, &Node{Value: 2}
+	oldA.Next, oldB.Next = oldB, oldA
+
+	newA, newB := &Node{Value: 1}, &Node{Value: 3}
+	newA.Next, newB.Next = newB, newA
+
+	changes, err := CompareStructsDeep(oldA, newA)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].Field != "Next.Value" || changes[0].OldValue.(int) != 2 || changes[0].NewValue.(int) != 3 {
+		t.Errorf("Cyclic list change not detected correctly: %+v", changes[0])
+	}
+
+	// The back reference to the changed head is reported once, as a reference
+	if changes[1].Field != "Next.Next" || changes[1].OldValue.(*Node) != oldA || changes[1].NewValue.(*Node) != newA {
+		t.Errorf("Expected a reference change on Next.Next, got %+v", changes[1])
+	}
+}
+
+func TestCompareStructsDeepTerminatesOnParentPointers(t *testing.T) {
+	oldRoot := &Node{Value: 1}
+	oldRoot.Next = &Node{Value: 2, Parent: oldRoot}
+
+	newRoot := &Node{Value: 10}
+	newRoot.Next = &Node{Value: 2, Parent: newRoot}
+
+	changes, err := CompareStructsDeep(oldRoot, newRoot)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+
+	// The root difference is walked once and reached again through Next.Parent as a reference
+	if len(changes) != 2 || changes[0].Field != "Value" || changes[1].Field != "Next.Parent" {
+		t.Errorf("Expected changes on Value and Next.Parent, got %+v", changes)
+	}
+	if changes[1].NewValue.(*Node) != newRoot {
+		t.Errorf("Expected Next.Parent to refer to the new root, got %+v", changes[1])
+	}
+}
+
+func TestCompareStructsDeepSkipsUnchangedReferences(t *testing.T) {
+	oldShared, newShared := &Node{Value: 5}, &Node{Value: 5}
+	old := &Node{Value: 1, Next: oldShared, Parent: oldShared}
+	new := &Node{Value: 1, Next: newShared, Parent: newShared}
+
+	changes, err := CompareStructsDeep(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestCompareStructsDeepFailsOnDifferentTypes(t *testing.T) {
+	_, err := CompareStructsDeep(Person{}, Node{})
+	if err == nil {
+		t.Error("Expected error when comparing different struct types")
+	}
+}
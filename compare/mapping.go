@@ -0,0 +1,110 @@
+package compare
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FieldMapper returns the name of the target struct field that a source struct field corresponds to,
+// and false when the source field has no counterpart
+type FieldMapper func(source reflect.StructField) (target string, ok bool)
+
+// TagMapper maps a source field to the target field named by its `sync:"field=Name"` tag.
+// Untagged fields map to the target field with the same name, and `sync:"-"` skips the field.
+func TagMapper(source reflect.StructField) (string, bool) {
+	if hasSyncOption(source, "-") {
+		return "", false
+	}
+	if target, ok := SyncOption(source, "field"); ok && target != "" {
+		return target, true
+	}
+	return source.Name, true
+}
+
+// MapFields returns a FieldMapper from an explicit source to target field name table.
+// Source fields missing from the table have no counterpart.
+func MapFields(mapping map[string]string) FieldMapper {
+	return func(source reflect.StructField) (string, bool) {
+		target, ok := mapping[source.Name]
+		return target, ok
+	}
+}
+
+// CompareMapped compares a source struct against a target struct of a different type, e.g. an API DTO
+// against the domain object it updates. Fields are paired through mapper, and the returned changes are
+// expressed in terms of the target: Field is the target field name, OldValue the target's current value
+// and NewValue the source value converted to the target field's type, ready for change.ApplyChanges.
+//
+// A nil pointer source field mapped onto a non-pointer target field counts as "not provided" and is
+// skipped, while a non-nil pointer is dereferenced. Numbers and numeric text are converted with
+// ConvertNumber, failing when the value would not survive, and other values as Go converts them.
+func CompareMapped(source, target interface{}, mapper FieldMapper) ([]Change, error) {
+	sourceVal := reflect.ValueOf(source)
+	targetVal := reflect.ValueOf(target)
+
+	// Dereference if pointers
+	if sourceVal.Kind() == reflect.Ptr {
+		sourceVal = sourceVal.Elem()
+	}
+	if targetVal.Kind() == reflect.Ptr {
+		targetVal = targetVal.Elem()
+	}
+
+	// Validate input types
+	if sourceVal.Kind() != reflect.Struct || targetVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("both arguments must be structs")
+	}
+
+	sourceType := sourceVal.Type()
+	targetType := targetVal.Type()
+	changes := make([]Change, 0, sourceVal.NumField())
+
+	for i := 0; i < sourceType.NumField(); i++ {
+		sourceField := sourceType.Field(i)
+		if !sourceField.IsExported() {
+			continue
+		}
+
+		targetName, ok := mapper(sourceField)
+		if !ok {
+			continue
+		}
+
+		targetField, ok := targetType.FieldByName(targetName)
+		if !ok || !targetField.IsExported() {
+			return nil, fmt.Errorf("field %s maps to unknown target field %s", sourceField.Name, targetName)
+		}
+
+		newValue := sourceVal.Field(i)
+		if newValue.Kind() == reflect.Ptr && targetField.Type.Kind() != reflect.Ptr {
+			if newValue.IsNil() {
+				continue
+			}
+			newValue = newValue.Elem()
+		}
+
+		if newValue.Type() != targetField.Type {
+			// Numbers convert like change.ApplyChanges converts them, the rest as Go converts them
+			converted, ok, err := ConvertNumber(newValue, targetField.Type)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert field %s value %v to %s (%s) without losing data: %w",
+					sourceField.Name, newValue.Interface(), targetName, targetField.Type, err)
+			}
+			if !ok {
+				if !newValue.Type().ConvertibleTo(targetField.Type) {
+					return nil, fmt.Errorf("cannot convert field %s (%s) to %s (%s)",
+						sourceField.Name, newValue.Type(), targetName, targetField.Type)
+				}
+				converted = newValue.Convert(targetField.Type)
+			}
+			newValue = converted
+		}
+
+		oldValue := targetVal.FieldByIndex(targetField.Index)
+		if !reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			changes = append(changes, newChange(targetName, targetField, oldValue, newValue, DefaultClassifier))
+		}
+	}
+
+	return changes, nil
+}
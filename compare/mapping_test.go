@@ -0,0 +1,160 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+)
+
+type User struct {
+	ID           int64
+	FullName     string
+	EmailAddress string
+	Age          int
+}
+
+type UserDTO struct {
+	Name     string  `sync:"field=FullName"`
+	Email    *string `sync:"field=EmailAddress"`
+	Age      int32
+	Password string `sync:"-"`
+}
+
+func TestCompareMappedWithTagMapper(t *testing.T) {
+	email := "new@example.com"
+	dto := UserDTO{Name: "Jane Doe", Email: &email, Age: 30, Password: "secret"}
+	user := User{ID: 1, FullName: "John Doe", EmailAddress: "old@example.com", Age: 30}
+
+	changes, err := CompareMapped(dto, user, TagMapper)
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+
+	nameChange := findChangeByField(changes, "FullName")
+	if nameChange == nil || nameChange.OldValue.(string) != "John Doe" || nameChange.NewValue.(string) != "Jane Doe" {
+		t.Errorf("FullName change not detected correctly: %+v", nameChange)
+	}
+
+	emailChange := findChangeByField(changes, "EmailAddress")
+	if emailChange == nil || emailChange.NewValue.(string) != "new@example.com" {
+		t.Errorf("EmailAddress change not detected correctly: %+v", emailChange)
+	}
+}
+
+func TestCompareMappedSkipsNilPointersAndConvertsTypes(t *testing.T) {
+	dto := UserDTO{Name: "John Doe", Email: nil, Age: 31}
+	user := User{FullName: "John Doe", EmailAddress: "old@example.com", Age: 30}
+
+	changes, err := CompareMapped(&dto, &user, TagMapper)
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d: %+v", len(changes), changes)
+	}
+	if age, ok := changes[0].NewValue.(int); !ok || age != 31 || changes[0].Field != "Age" {
+		t.Errorf("Expected Age converted to int, got %#v", changes[0])
+	}
+}
+
+func TestCompareMappedWithMapFields(t *testing.T) {
+	type Form struct {
+		DisplayName string
+		Ignored     string
+	}
+
+	changes, err := CompareMapped(Form{DisplayName: "Jane", Ignored: "x"}, User{FullName: "John"},
+		MapFields(map[string]string{"DisplayName": "FullName"}))
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Field != "FullName" {
+		t.Errorf("Expected only FullName change, got %+v", changes)
+	}
+}
+
+func TestCompareMappedWithCustomMapper(t *testing.T) {
+	mapper := func(source reflect.StructField) (string, bool) {
+		if source.Name == "Name" {
+			return "FullName", true
+		}
+		return "", false
+	}
+
+	changes, err := CompareMapped(UserDTO{Name: "Jane"}, User{FullName: "John"}, mapper)
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Field != "FullName" {
+		t.Errorf("Expected only FullName change, got %+v", changes)
+	}
+}
+
+func TestCompareMappedFailsOnUnknownTargetField(t *testing.T) {
+	_, err := CompareMapped(UserDTO{}, User{}, MapFields(map[string]string{"Name": "Missing"}))
+	if err == nil {
+		t.Error("Expected error when mapping to a field the target does not have")
+	}
+}
+
+func TestCompareMappedFailsOnInconvertibleTypes(t *testing.T) {
+	_, err := CompareMapped(UserDTO{}, User{}, MapFields(map[string]string{"Name": "Age"}))
+	if err == nil {
+		t.Error("Expected error when source and target field types are not convertible")
+	}
+}
+
+func TestCompareMappedFormatsNumbersAsText(t *testing.T) {
+	// Like change.ApplyChanges, an integer becomes its decimal text rather than the rune it encodes
+	changes, err := CompareMapped(UserDTO{Age: 65}, User{}, MapFields(map[string]string{"Age": "FullName"}))
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].NewValue != "65" {
+		t.Errorf("Expected FullName to become \"65\", got %+v", changes)
+	}
+}
+
+func TestCompareMappedFailsOnLossyConversions(t *testing.T) {
+	type Limits struct {
+		Small    int8
+		Count    int
+		Unsigned uint16
+		Ratio    float32
+	}
+
+	tests := []struct {
+		name   string
+		source interface{}
+	}{
+		{"overflow", struct{ Small int64 }{300}},
+		{"fraction", struct{ Count float64 }{3.5}},
+		{"negative", struct{ Unsigned int }{-1}},
+		{"float range", struct{ Ratio float64 }{1e300}},
+		{"float rounding", struct{ Ratio float64 }{0.1234567891}},
+	}
+
+	for _, test := range tests {
+		if _, err := CompareMapped(test.source, Limits{}, TagMapper); err == nil {
+			t.Errorf("%s: expected error for a conversion that loses data", test.name)
+		}
+	}
+
+	changes, err := CompareMapped(struct {
+		Small int64
+		Count float64
+		Ratio float64
+	}{-128, 42, 0.1}, Limits{}, TagMapper)
+	if err != nil {
+		t.Fatalf("CompareMapped failed: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 changes for values that fit, got %+v", changes)
+	}
+}
@@ -0,0 +1,192 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CompareStructToMap compares a struct against a generic map, such as a decoded JSON object, and returns
+// the changes binding the map onto the struct would make. Map keys are matched against the field's json
+// tag name (or the field name when untagged), case-insensitively like encoding/json. Fields without a key
+// in the map are left unchanged, and keys that match no field are ignored.
+//
+// Map values are decoded into the field's type, so the resulting changes carry typed NewValues that can be
+// passed straight to change.ApplyChanges.
+func CompareStructToMap(s interface{}, m map[string]interface{}) ([]Change, error) {
+	structVal := reflect.ValueOf(s)
+
+	// Dereference if pointer
+	if structVal.Kind() == reflect.Ptr {
+		structVal = structVal.Elem()
+	}
+
+	if structVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("first argument must be a struct")
+	}
+
+	structType := structVal.Type()
+	changes := make([]Change, 0, len(m))
+
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		key, ok := JSONFieldName(structField)
+		if !ok {
+			continue
+		}
+
+		raw, ok := lookupKey(m, key)
+		if !ok {
+			continue
+		}
+
+		oldField := structVal.Field(i)
+		newField, err := decodeInto(raw, structField.Type, oldField)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+		}
+
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			changes = append(changes, newChange(structField.Name, structField, oldField, newField, DefaultClassifier))
+		}
+	}
+
+	return changes, nil
+}
+
+// CompareStructToJSON compares a struct against a JSON object like CompareStructToMap
+func CompareStructToJSON(s interface{}, data []byte) ([]Change, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return CompareStructToMap(s, m)
+}
+
+// TypedChanges - decodes change values read back from JSON (float64, []interface{}, map[string]interface{})
+// into the types of the target struct fields they belong to, following dotted paths from CompareStructsDeep,
+// so they can be passed to change.ApplyChanges. Changes to fields target does not have are migrated with
+// MigrateUnknownFields first, and returned untouched when they still do not match a field.
+func TypedChanges(target interface{}, changes []Change) ([]Change, error) {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return nil, err
+	}
+	if changes, err = MigrateUnknownFields(target, changes); err != nil {
+		return nil, err
+	}
+
+	typed := make([]Change, len(changes))
+	for i, change := range changes {
+		typed[i] = change
+
+		field, ok := fieldByPath(structType, change.Field)
+		if !ok {
+			continue
+		}
+
+		for _, value := range []*interface{}{&typed[i].OldValue, &typed[i].NewValue} {
+			if *value == nil || reflect.TypeOf(*value).AssignableTo(field.Type) {
+				continue
+			}
+			decoded, err := decodeInto(*value, field.Type, reflect.Value{})
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", change.Field, err)
+			}
+			*value = decoded.Interface()
+		}
+	}
+	return typed, nil
+}
+
+// fieldByPath - resolves a dotted field path in a struct type, following pointers to structs like
+// ApplyChanges does
+func fieldByPath(structType reflect.Type, path string) (reflect.StructField, bool) {
+	var field reflect.StructField
+	for i, name := range strings.Split(path, ".") {
+		if i > 0 {
+			structType = field.Type
+			if structType.Kind() == reflect.Ptr {
+				structType = structType.Elem()
+			}
+			if structType.Kind() != reflect.Struct {
+				return reflect.StructField{}, false
+			}
+		}
+
+		var ok bool
+		if field, ok = structType.FieldByName(name); !ok {
+			return reflect.StructField{}, false
+		}
+	}
+	return field, true
+}
+
+// JSONFieldName - returns the key encoding/json uses for a struct field, and false when the field is skipped
+func JSONFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// lookupKey - finds a map entry by exact key first, then case-insensitively
+func lookupKey(m map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := m[key]; ok {
+		return value, true
+	}
+	for k, value := range m {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// decodeInto - converts a generic value into a value of the given type by round-tripping it through JSON.
+// When base is valid the value is decoded onto a copy of it, so like json.Unmarshal a partial object only
+// overwrites the fields it names and a null leaves values other than pointers, interfaces, maps and
+// slices unchanged.
+func decodeInto(raw interface{}, t reflect.Type, base reflect.Value) (reflect.Value, error) {
+	target := reflect.New(t)
+	if raw == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		default:
+			if base.IsValid() {
+				return base, nil
+			}
+		}
+		return target.Elem(), nil
+	}
+
+	// Fast path for values that already have the right type
+	rawVal := reflect.ValueOf(raw)
+	if rawVal.Type() == t {
+		return rawVal, nil
+	}
+
+	if base.IsValid() {
+		target.Elem().Set(cloneOf(base))
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := json.Unmarshal(data, target.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot decode value into %s: %w", t, err)
+	}
+	return target.Elem(), nil
+}
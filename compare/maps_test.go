@@ -0,0 +1,204 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type Account struct {
+	ID       int64    `json:"id"`
+	Email    string   `json:"email"`
+	Nickname string   `json:"nickname,omitempty"`
+	Roles    []string `json:"roles"`
+	Secret   string   `json:"-"`
+	Verified bool
+}
+
+func TestCompareStructToMapUsesJSONTags(t *testing.T) {
+	account := Account{ID: 7, Email: "old@example.com", Roles: []string{"user"}}
+	payload := map[string]interface{}{
+		"id":    float64(7),
+		"email": "new@example.com",
+		"roles": []interface{}{"user", "admin"},
+	}
+
+	changes, err := CompareStructToMap(account, payload)
+	if err != nil {
+		t.Fatalf("CompareStructToMap failed: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+
+	emailChange := findChangeByField(changes, "Email")
+	if emailChange == nil || emailChange.ChangeType != Modified || emailChange.NewValue.(string) != "new@example.com" {
+		t.Errorf("Email change not detected correctly: %+v", emailChange)
+	}
+
+	rolesChange := findChangeByField(changes, "Roles")
+	if rolesChange == nil {
+		t.Fatalf("Roles change not detected")
+	}
+	if roles, ok := rolesChange.NewValue.([]string); !ok || len(roles) != 2 {
+		t.Errorf("Expected typed []string NewValue, got %#v", rolesChange.NewValue)
+	}
+}
+
+func TestCompareStructToMapIgnoresMissingUnknownAndSkippedKeys(t *testing.T) {
+	account := Account{ID: 7, Email: "a@example.com", Secret: "s"}
+	payload := map[string]interface{}{
+		"Secret":  "leaked",
+		"unknown": 1,
+	}
+
+	changes, err := CompareStructToMap(account, payload)
+	if err != nil {
+		t.Fatalf("CompareStructToMap failed: %v", err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestCompareStructToMapTreatsNullLikeJSONUnmarshal(t *testing.T) {
+	account := Account{ID: 7, Nickname: "jd", Roles: []string{"user"}, Verified: true}
+	data := []byte(`{"id":null,"nickname":null,"roles":null,"verified":false}`)
+
+	changes, err := CompareStructToJSON(&account, data)
+	if err != nil {
+		t.Fatalf("CompareStructToJSON failed: %v", err)
+	}
+
+	// encoding/json leaves non-pointer, non-slice, non-map fields alone on null
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+	if c := findChangeByField(changes, "Roles"); c == nil || c.ChangeType != Deleted {
+		t.Errorf("Expected Roles to be Deleted, got %+v", c)
+	}
+	if c := findChangeByField(changes, "Verified"); c == nil || c.NewValue.(bool) != false {
+		t.Errorf("Expected case-insensitive match on Verified, got %+v", c)
+	}
+
+	unmarshaled := account
+	if err := json.Unmarshal(data, &unmarshaled); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if unmarshaled.ID != 7 || unmarshaled.Nickname != "jd" || unmarshaled.Roles != nil {
+		t.Errorf("Expected json.Unmarshal to agree, got %+v", unmarshaled)
+	}
+}
+
+func TestCompareStructToMapFailsOnUndecodableValue(t *testing.T) {
+	_, err := CompareStructToMap(Account{}, map[string]interface{}{"id": "not a number"})
+	if err == nil {
+		t.Error("Expected error when a value cannot be decoded into the field type")
+	}
+}
+
+type Squad struct {
+	Name    string   `json:"name"`
+	Lead    Account  `json:"lead"`
+	Manager *Account `json:"manager"`
+}
+
+func TestCompareStructToMapKeepsFieldsMissingFromNestedObjects(t *testing.T) {
+	manager := &Account{ID: 1, Email: "boss@example.com", Roles: []string{"admin"}}
+	squad := Squad{Name: "core", Lead: Account{ID: 2, Email: "lead@example.com"}, Manager: manager}
+
+	changes, err := CompareStructToJSON(squad, []byte(`{"lead": {"email": "new@example.com"}, "manager": {"roles": ["owner"]}}`))
+	if err != nil {
+		t.Fatalf("CompareStructToJSON failed: %v", err)
+	}
+
+	lead := findChangeByField(changes, "Lead")
+	if lead == nil {
+		t.Fatalf("Lead change not detected: %+v", changes)
+	}
+	if got := lead.NewValue.(Account); got.ID != 2 || got.Email != "new@example.com" {
+		t.Errorf("Expected lead to keep its ID, got %+v", got)
+	}
+
+	managerChange := findChangeByField(changes, "Manager")
+	if managerChange == nil {
+		t.Fatalf("Manager change not detected: %+v", changes)
+	}
+	if got := managerChange.NewValue.(*Account); got.ID != 1 || got.Email != "boss@example.com" || got.Roles[0] != "owner" {
+		t.Errorf("Expected manager to keep its ID and email, got %+v", got)
+	}
+
+	// Decoding must not write through to the compared struct
+	if manager.Roles[0] != "admin" || squad.Manager != manager {
+		t.Errorf("Expected original manager to be unchanged, got %+v", manager)
+	}
+}
+
+func TestCompareStructToJSON(t *testing.T) {
+	changes, err := CompareStructToJSON(Account{ID: 1}, []byte(`{"id": 2}`))
+	if err != nil {
+		t.Fatalf("CompareStructToJSON failed: %v", err)
+	}
+
+	if c := findChangeByField(changes, "ID"); c == nil || c.NewValue.(int64) != 2 {
+		t.Errorf("ID change not detected correctly: %+v", changes)
+	}
+
+	if _, err := CompareStructToJSON(Account{}, []byte(`[1, 2]`)); err == nil {
+		t.Error("Expected error for a JSON document that is not an object")
+	}
+}
+
+func TestTypedChangesDecodesJSONValues(t *testing.T) {
+	jsonData, err := ChangesToJSON([]Change{
+		{Field: "ID", ChangeType: Modified, OldValue: int64(1), NewValue: int64(2)},
+		{Field: "Roles", ChangeType: Added, NewValue: []string{"admin"}},
+		{Field: "Unknown", ChangeType: Added, NewValue: 1},
+	})
+	if err != nil {
+		t.Fatalf("ChangesToJSON failed: %v", err)
+	}
+	restored, err := ChangesFromJSON(jsonData)
+	if err != nil {
+		t.Fatalf("ChangesFromJSON failed: %v", err)
+	}
+
+	typed, err := TypedChanges(&Account{}, restored)
+	if err != nil {
+		t.Fatalf("TypedChanges failed: %v", err)
+	}
+
+	if id, ok := typed[0].NewValue.(int64); !ok || id != 2 || typed[0].OldValue.(int64) != 1 {
+		t.Errorf("Expected int64 values, got %#v", typed[0])
+	}
+	if roles, ok := typed[1].NewValue.([]string); !ok || roles[0] != "admin" {
+		t.Errorf("Expected []string value, got %#v", typed[1].NewValue)
+	}
+	if typed[2].NewValue.(float64) != 1 {
+		t.Errorf("Expected unknown field to be left untouched")
+	}
+}
+
+func TestTypedChangesDecodesNestedPaths(t *testing.T) {
+	changes := []Change{
+		{Field: "Manager.Age", ChangeType: Modified, OldValue: float64(50), NewValue: float64(51)},
+		{Field: "Manager.Manager.Name", ChangeType: Added, NewValue: "CEO"},
+		{Field: "Name.Length", ChangeType: Added, NewValue: float64(1)},
+	}
+
+	typed, err := TypedChanges(Person{}, changes)
+	if err != nil {
+		t.Fatalf("TypedChanges failed: %v", err)
+	}
+
+	if age, ok := typed[0].NewValue.(int); !ok || age != 51 || typed[0].OldValue.(int) != 50 {
+		t.Errorf("Expected int values for Manager.Age, got %#v", typed[0])
+	}
+	if typed[1].NewValue != "CEO" {
+		t.Errorf("Expected Manager.Manager.Name to be CEO, got %#v", typed[1].NewValue)
+	}
+	if typed[2].NewValue.(float64) != 1 {
+		t.Errorf("Expected a path through a non-struct field to be left untouched")
+	}
+}
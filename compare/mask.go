@@ -0,0 +1,122 @@
+package compare
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MaskError is returned when a change touches a field outside the field mask it is checked against
+type MaskError struct {
+	Field string
+	Mask  []string
+}
+
+// Error implements the error interface
+func (e *MaskError) Error() string {
+	return fmt.Sprintf("field %s is outside the field mask [%s]", e.Field, strings.Join(e.Mask, ", "))
+}
+
+// InMask reports whether a dotted field path is covered by mask, either because it is listed itself or
+// because it is nested under a listed path
+func InMask(field string, mask []string) bool {
+	for _, path := range mask {
+		if field == path || strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckMask returns a *MaskError for the first change outside mask, nil if every change is covered
+func CheckMask(changes []Change, mask []string) error {
+	for _, change := range changes {
+		if !InMask(change.Field, mask) {
+			return &MaskError{Field: change.Field, Mask: mask}
+		}
+	}
+	return nil
+}
+
+// CompareStructsMasked compares two struct instances like CompareStructsDeep, restricted to the fields
+// listed in mask. Mask paths are dotted field names ("Manager.Name"), and a path naming a struct covers
+// every field nested under it. Changes are returned in mask order. A nil pointer along a path reads as
+// the zero value, and a path that does not exist in the struct is an error.
+func CompareStructsMasked(old, new interface{}, mask []string) ([]Change, error) {
+	oldVal := reflect.ValueOf(old)
+	newVal := reflect.ValueOf(new)
+
+	// Dereference if pointers
+	if oldVal.Kind() == reflect.Ptr {
+		oldVal = oldVal.Elem()
+	}
+	if newVal.Kind() == reflect.Ptr {
+		newVal = newVal.Elem()
+	}
+
+	// Validate input types
+	if oldVal.Kind() != reflect.Struct || newVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("both arguments must be structs")
+	}
+	if oldVal.Type() != newVal.Type() {
+		return nil, fmt.Errorf("both structs must be of the same type")
+	}
+
+	visited := make(map[visit]bool)
+	changes := make([]Change, 0, len(mask))
+	for i, path := range mask {
+		// Paths covered by another entry would report their changes twice
+		if isCovered(mask, i) {
+			continue
+		}
+
+		oldField, newField, structField, err := fieldAtPath(oldVal, newVal, path)
+		if err != nil {
+			return nil, err
+		}
+		diffField(path, structField, oldField, newField, visited, DefaultClassifier, &changes)
+	}
+	return changes, nil
+}
+
+// isCovered - reports whether mask[i] repeats an earlier entry or is nested under another entry
+func isCovered(mask []string, i int) bool {
+	for j, other := range mask {
+		if strings.HasPrefix(mask[i], other+".") || (other == mask[i] && j < i) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAtPath - resolves a dotted field path in two struct values of the same type
+func fieldAtPath(oldVal, newVal reflect.Value, path string) (reflect.Value, reflect.Value, reflect.StructField, error) {
+	var structField reflect.StructField
+	for i, name := range strings.Split(path, ".") {
+		if i > 0 {
+			oldVal, newVal = elemOrZero(oldVal), elemOrZero(newVal)
+			if oldVal.Kind() != reflect.Struct {
+				return reflect.Value{}, reflect.Value{}, structField, fmt.Errorf("field %s is not a struct", structField.Name)
+			}
+		}
+
+		field, ok := oldVal.Type().FieldByName(name)
+		if !ok || !field.IsExported() || len(field.Index) != 1 {
+			return reflect.Value{}, reflect.Value{}, structField, fmt.Errorf("field %s not found", path)
+		}
+		structField = field
+		oldVal, newVal = oldVal.Field(field.Index[0]), newVal.Field(field.Index[0])
+	}
+	return oldVal, newVal, structField, nil
+}
+
+// elemOrZero - follows a pointer, reading a nil pointer as the zero value of its element type
+func elemOrZero(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
@@ -0,0 +1,79 @@
+package compare
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInMask(t *testing.T) {
+	mask := []string{"Name", "Manager.Age"}
+	tests := map[string]bool{
+		"Name":         true,
+		"Manager.Age":  true,
+		"Manager":      false,
+		"Manager.Name": false,
+		"NameSuffix":   false,
+	}
+	for field, expected := range tests {
+		if got := InMask(field, mask); got != expected {
+			t.Errorf("InMask(%s): expected %v, got %v", field, expected, got)
+		}
+	}
+}
+
+func TestCompareStructsMaskedRestrictsToMask(t *testing.T) {
+	old := Person{Name: "John", Age: 30, Manager: &Person{Name: "Boss", Age: 50}}
+	new := Person{Name: "Jane", Age: 31, Manager: &Person{Name: "Chief", Age: 51}}
+
+	changes, err := CompareStructsMasked(old, new, []string{"Manager.Age", "Name", "Manager.Age"})
+	if err != nil {
+		t.Fatalf("CompareStructsMasked failed: %v", err)
+	}
+
+	if len(changes) != 2 || changes[0].Field != "Manager.Age" || changes[1].Field != "Name" {
+		t.Errorf("Expected Manager.Age and Name in mask order, got %+v", changes)
+	}
+}
+
+func TestCompareStructsMaskedDescendsIntoMaskedStructs(t *testing.T) {
+	old := Person{Name: "John"}
+	new := Person{Name: "John", Manager: &Person{Name: "Boss"}}
+
+	// A nil pointer reads as the zero value, so the nested field is reported on its own
+	changes, err := CompareStructsMasked(old, new, []string{"Manager.Name", "Manager"})
+	if err != nil {
+		t.Fatalf("CompareStructsMasked failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Field != "Manager" || changes[0].ChangeType != Added {
+		t.Errorf("Expected the covering Manager path only, got %+v", changes)
+	}
+
+	changes, err = CompareStructsMasked(old, new, []string{"Manager.Name"})
+	if err != nil {
+		t.Fatalf("CompareStructsMasked failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Field != "Manager.Name" || changes[0].NewValue != "Boss" {
+		t.Errorf("Expected Manager.Name, got %+v", changes)
+	}
+}
+
+func TestCompareStructsMaskedFailsOnUnknownPath(t *testing.T) {
+	for _, path := range []string{"Missing", "Name.First", "Manager.Missing"} {
+		if _, err := CompareStructsMasked(Person{}, Person{}, []string{path}); err == nil {
+			t.Errorf("Expected error for mask path %s", path)
+		}
+	}
+}
+
+func TestCheckMaskReturnsMaskError(t *testing.T) {
+	changes := []Change{{Field: "Manager.Name"}, {Field: "Age"}}
+
+	err := CheckMask(changes, []string{"Manager"})
+	var maskErr *MaskError
+	if !errors.As(err, &maskErr) || maskErr.Field != "Age" {
+		t.Errorf("Expected MaskError for Age, got %v", err)
+	}
+	if err := CheckMask(changes, []string{"Manager", "Age"}); err != nil {
+		t.Errorf("Expected changes to be covered, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Migration describes how changes recorded against an earlier version of a struct carry over to its
+// current version. Field paths may be nested ("Address.City"), and renaming or dropping a struct field
+// covers the fields nested under it.
+type Migration struct {
+	// Rename maps old field paths to their current path
+	Rename map[string]string
+
+	// Drop lists fields that no longer exist, changes to them are discarded
+	Drop []string
+
+	// Convert maps a current field path to a function converting recorded values into the field's
+	// current type. Converters see both OldValue and NewValue, which may also be JSON-decoded.
+	Convert map[string]func(value interface{}) (interface{}, error)
+
+	// From and To are the SchemaHash of the versions the migration upgrades from and to, an empty To
+	// meaning the current version. A change set is migrated along the chain of migrations starting at
+	// its own schema, so a later version may reuse a field name an earlier one renamed away.
+	From string
+	To   string
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string][]Migration)
+	schemas      = make(map[string]string)
+)
+
+// RegisterMigration registers a migration for the struct type of target, whose schema becomes the one
+// migrated change sets are recorded against. A type's migrations run in registration order, so a field
+// renamed twice is registered as two migrations, oldest first.
+//
+// Change sets recorded against a different schema of the type are migrated when they are decoded, or
+// with ChangeSet.Migrate, and ApplyChanges and TypedChanges migrate plain changes to fields the type no
+// longer has, so change logs recorded against older versions of a type keep replaying against the
+// current one.
+func RegisterMigration(target interface{}, migration Migration) error {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return err
+	}
+
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	name := typeNameOf(structType)
+	migrations[name] = append(migrations[name], migration)
+	schemas[name] = schemaHashOf(structType)
+	return nil
+}
+
+// Migrate rewrites changes recorded against an earlier version of target's type with all migrations
+// registered for it. Changes are returned unchanged when the type has none. Plain change lists do not
+// record their schema, so only pass changes known to be older than the current type; change sets are
+// migrated by schema with ChangeSet.Migrate.
+func Migrate(target interface{}, changes []Change) ([]Change, error) {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return nil, err
+	}
+	return migrate(typeNameOf(structType), changes)
+}
+
+// MigrateUnknownFields rewrites the changes to field paths target's type does not have with all
+// migrations registered for it, leaving changes to its current fields alone. ApplyChanges and
+// TypedChanges use it so plain change lists recorded against older versions keep applying.
+func MigrateUnknownFields(target interface{}, changes []Change) ([]Change, error) {
+	structType, err := structTypeOf(target)
+	if err != nil {
+		return nil, err
+	}
+
+	typeName := typeNameOf(structType)
+	migrationsMu.RLock()
+	registered := len(migrations[typeName]) > 0
+	migrationsMu.RUnlock()
+	if !registered {
+		return changes, nil
+	}
+
+	migrated := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		if _, ok := fieldByPath(structType, change.Field); ok {
+			migrated = append(migrated, change)
+			continue
+		}
+		carried, err := migrate(typeName, []Change{change})
+		if err != nil {
+			return nil, err
+		}
+		migrated = append(migrated, carried...)
+	}
+	return migrated, nil
+}
+
+// Migrate returns the change set with its changes migrated to the current schema of its type, when it
+// was recorded against a different schema and migrations are registered for the type. Migrations run
+// along the chain from the change set's schema, each one's To matching the next one's From, and the
+// migrated change set carries the schema the last one upgraded to, so AppliesTo still checks it against
+// the target. It fails when no registered migration upgrades from the change set's schema.
+func (cs ChangeSet) Migrate() (ChangeSet, error) {
+	migrationsMu.RLock()
+	current, ok := schemas[cs.TypeName]
+	registered := migrations[cs.TypeName]
+	migrationsMu.RUnlock()
+	if !ok || cs.SchemaHash == current {
+		return cs, nil
+	}
+
+	changes, schema := cs.Changes, cs.SchemaHash
+	// Every migration runs at most once, even when the chain loops
+	for range registered {
+		i := slices.IndexFunc(registered, func(m Migration) bool { return m.From == schema })
+		if i < 0 {
+			break
+		}
+
+		var err error
+		if changes, err = runMigration(registered[i], changes); err != nil {
+			return ChangeSet{}, fmt.Errorf("change set %s: %w", cs.ID, err)
+		}
+		if schema = registered[i].To; schema == "" || schema == current {
+			schema = current
+			break
+		}
+	}
+	if schema == cs.SchemaHash {
+		return ChangeSet{}, fmt.Errorf("change set %s: no migration of %s upgrades from schema %s", cs.ID, cs.TypeName, cs.SchemaHash)
+	}
+
+	cs.Changes = changes
+	cs.SchemaHash = schema
+	return cs, nil
+}
+
+// migrate - applies every migration registered for the named type, in registration order
+func migrate(typeName string, changes []Change) ([]Change, error) {
+	migrationsMu.RLock()
+	registered := migrations[typeName]
+	migrationsMu.RUnlock()
+
+	for _, migration := range registered {
+		var err error
+		if changes, err = runMigration(migration, changes); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// runMigration - rewrites changes with a single migration, returning a new list
+func runMigration(migration Migration, changes []Change) ([]Change, error) {
+	migrated := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		if isDropped(change.Field, migration.Drop) {
+			continue
+		}
+
+		// The most specific rename wins when both a struct and a field inside it were renamed
+		renamed := ""
+		for from := range migration.Rename {
+			if (change.Field == from || strings.HasPrefix(change.Field, from+".")) && len(from) > len(renamed) {
+				renamed = from
+			}
+		}
+		if renamed != "" {
+			change.Field = migration.Rename[renamed] + strings.TrimPrefix(change.Field, renamed)
+		}
+
+		if convert, ok := migration.Convert[change.Field]; ok {
+			var err error
+			if change.OldValue, err = convertValue(convert, change.OldValue); err != nil {
+				return nil, fmt.Errorf("field %s: %w", change.Field, err)
+			}
+			if change.NewValue, err = convertValue(convert, change.NewValue); err != nil {
+				return nil, fmt.Errorf("field %s: %w", change.Field, err)
+			}
+		}
+		migrated = append(migrated, change)
+	}
+	return migrated, nil
+}
+
+// isDropped - reports whether field is one of the dropped paths or nested under one
+func isDropped(field string, dropped []string) bool {
+	for _, path := range dropped {
+		if field == path || strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// convertValue - runs a converter on a non-nil value
+func convertValue(convert func(interface{}) (interface{}, error), value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return convert(value)
+}
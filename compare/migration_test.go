@@ -0,0 +1,210 @@
+package compare
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+type ProfileV3 struct {
+	FullName string
+	Age      int
+	Address  struct{ City string }
+}
+
+func init() {
+	// v1 -> v2: Name became DisplayName, Nickname was dropped, Age was stored as a string
+	RegisterMigration(ProfileV3{}, Migration{
+		From:   "v1",
+		To:     "v2",
+		Rename: map[string]string{"Name": "DisplayName", "Addr": "Address"},
+		Drop:   []string{"Nickname"},
+		Convert: map[string]func(interface{}) (interface{}, error){
+			"Age": func(value interface{}) (interface{}, error) {
+				if s, ok := value.(string); ok {
+					return strconv.Atoi(s)
+				}
+				return value, nil
+			},
+		},
+	})
+	// v2 -> v3: DisplayName became FullName
+	RegisterMigration(ProfileV3{}, Migration{From: "v2", Rename: map[string]string{"DisplayName": "FullName"}})
+}
+
+func TestMigrateRenamesDropsAndConverts(t *testing.T) {
+	changes := []Change{
+		{Field: "Name", ChangeType: Modified, OldValue: "a", NewValue: "b"},
+		{Field: "Nickname", ChangeType: Added, NewValue: "bee"},
+		{Field: "Age", ChangeType: Modified, OldValue: "30", NewValue: "31"},
+		{Field: "Addr.City", ChangeType: Modified, OldValue: "Utrecht", NewValue: "Delft"},
+		{Field: "FullName", ChangeType: Modified, OldValue: "b", NewValue: "c"},
+	}
+
+	migrated, err := Migrate(ProfileV3{}, changes)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	expected := []Change{
+		{Field: "FullName", ChangeType: Modified, OldValue: "a", NewValue: "b"},
+		{Field: "Age", ChangeType: Modified, OldValue: 30, NewValue: 31},
+		{Field: "Address.City", ChangeType: Modified, OldValue: "Utrecht", NewValue: "Delft"},
+		{Field: "FullName", ChangeType: Modified, OldValue: "b", NewValue: "c"},
+	}
+	if fmt.Sprint(migrated) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, migrated)
+	}
+	if changes[0].Field != "Name" {
+		t.Error("Expected the input changes to be left untouched")
+	}
+}
+
+func TestMigrateFailsOnConversionError(t *testing.T) {
+	_, err := Migrate(&ProfileV3{}, []Change{{Field: "Age", ChangeType: Modified, NewValue: "thirty"}})
+	if err == nil {
+		t.Error("Expected error when a converter fails")
+	}
+}
+
+func TestChangeSetFromJSONMigratesChanges(t *testing.T) {
+	cs, err := NewChangeSet(ProfileV3{}, nil)
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+
+	// Recorded against an older schema of the type
+	data := fmt.Sprintf(`{"id":"1","type_name":%q,"schema_hash":"v1","changes":[`+
+		`{"Field":"Name","ChangeType":"modified","OldValue":"a","NewValue":"b"},`+
+		`{"Field":"Nickname","ChangeType":"added","OldValue":"","NewValue":"bee"}]}`, cs.TypeName)
+
+	decoded, err := ChangeSetFromJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("ChangeSetFromJSON failed: %v", err)
+	}
+	if len(decoded.Changes) != 1 || decoded.Changes[0].Field != "FullName" {
+		t.Errorf("Expected a single FullName change, got %+v", decoded.Changes)
+	}
+	if err := decoded.AppliesTo(ProfileV3{}); err != nil {
+		t.Errorf("Expected a migrated change set to apply, got %v", err)
+	}
+	if decoded.SchemaHash != cs.SchemaHash {
+		t.Errorf("Expected the migrated change set to carry schema %s, got %s", cs.SchemaHash, decoded.SchemaHash)
+	}
+}
+
+func TestChangeSetMigratesOnlyOlderSchemas(t *testing.T) {
+	cs, err := NewChangeSet(ProfileV3{}, []Change{
+		{Field: "Age", ChangeType: Modified, OldValue: "30", NewValue: "31"},
+		{Field: "Nickname", ChangeType: Added, NewValue: "bee"},
+	})
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+
+	migrated, err := cs.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if fmt.Sprint(migrated.Changes) != fmt.Sprint(cs.Changes) {
+		t.Errorf("Expected changes of the current schema to be left alone, got %v", migrated.Changes)
+	}
+
+	cs.SchemaHash = "v1"
+	if err := cs.AppliesTo(ProfileV3{}); err == nil {
+		t.Error("Expected an unmigrated change set of an older schema to be rejected")
+	}
+	if migrated, err = cs.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(migrated.Changes) != 1 || migrated.Changes[0].NewValue != 31 {
+		t.Errorf("Expected the older change set to be migrated, got %v", migrated.Changes)
+	}
+	if err := migrated.AppliesTo(ProfileV3{}); err != nil {
+		t.Errorf("Expected the migrated change set to apply, got %v", err)
+	}
+}
+
+type Contact struct {
+	Label string
+	Name  string
+}
+
+func init() {
+	// v1 -> v2: Name became Label; v2 -> v3: a new Name field was added
+	RegisterMigration(Contact{}, Migration{From: "v1", To: "v2", Rename: map[string]string{"Name": "Label"}})
+	RegisterMigration(Contact{}, Migration{From: "v2"})
+}
+
+func TestChangeSetMigratesFromItsOwnSchema(t *testing.T) {
+	changes := []Change{{Field: "Name", ChangeType: Modified, OldValue: "a", NewValue: "b"}}
+	for schema, expected := range map[string]string{"v1": "Label", "v2": "Name"} {
+		cs := ChangeSet{ID: schema, TypeName: TypeName(Contact{}), SchemaHash: schema, Changes: changes}
+		migrated, err := cs.Migrate()
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if migrated.Changes[0].Field != expected {
+			t.Errorf("Expected a change set of %s to change %s, got %s", schema, expected, migrated.Changes[0].Field)
+		}
+	}
+}
+
+func TestChangeSetMigrationFailsWithoutMatchingSchema(t *testing.T) {
+	cs := ChangeSet{ID: "1", TypeName: TypeName(Contact{}), SchemaHash: "v0", Changes: []Change{{Field: "Name", NewValue: "a"}}}
+	if _, err := cs.Migrate(); err == nil {
+		t.Error("Expected error when no migration upgrades from the change set's schema")
+	}
+}
+
+type Ledger struct {
+	Total int
+}
+
+func init() {
+	// Only the first step of the chain is known, v2 -> current is missing
+	RegisterMigration(Ledger{}, Migration{From: "v1", To: "v2", Rename: map[string]string{"Sum": "Amount"}})
+}
+
+func TestChangeSetMigrationStopsAtTheEndOfItsChain(t *testing.T) {
+	cs := ChangeSet{ID: "1", TypeName: TypeName(Ledger{}), SchemaHash: "v1", Changes: []Change{{Field: "Sum", NewValue: 1}}}
+
+	migrated, err := cs.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if migrated.SchemaHash != "v2" || migrated.Changes[0].Field != "Amount" {
+		t.Errorf("Expected Amount at schema v2, got %+v", migrated)
+	}
+	if err := migrated.AppliesTo(Ledger{}); err == nil {
+		t.Error("Expected a partially migrated change set to be rejected")
+	}
+}
+
+func TestTypedChangesMigratesUnknownFields(t *testing.T) {
+	typed, err := TypedChanges(ProfileV3{}, []Change{
+		{Field: "DisplayName", ChangeType: Modified, OldValue: "a", NewValue: "b"},
+		{Field: "Nickname", ChangeType: Added, NewValue: "bee"},
+		{Field: "Age", ChangeType: Modified, OldValue: float64(30), NewValue: float64(31)},
+	})
+	if err != nil {
+		t.Fatalf("TypedChanges failed: %v", err)
+	}
+
+	if len(typed) != 2 || typed[0].Field != "FullName" || typed[1].NewValue != 31 {
+		t.Errorf("Expected FullName and a typed Age change, got %v", typed)
+	}
+}
+
+func TestChangesFromJSONMigratesChangeSets(t *testing.T) {
+	data := fmt.Sprintf(`{"id":"1","type_name":%q,"schema_hash":"v2","changes":[`+
+		`{"Field":"DisplayName","ChangeType":"modified","OldValue":"a","NewValue":"b"}]}`, TypeName(ProfileV3{}))
+
+	changes, err := ChangesFromJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("ChangesFromJSON failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Field != "FullName" {
+		t.Errorf("Expected a FullName change, got %+v", changes)
+	}
+}
@@ -0,0 +1,168 @@
+package compare
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ConvertNumber converts value to target when one of them is a number and the other a number or text,
+// reporting whether it applies to the pair at all. Numbers convert to other numeric types only when
+// they keep their exact value, so conversions that would truncate a fraction, round to a narrower float
+// or overflow fail. A float32 value that went through JSON arrives as the float64 of its shortest
+// decimal form, which is accepted for that float32. Numbers are formatted as decimal text, never as the
+// rune an integer encodes, and text is parsed with the same range checks.
+func ConvertNumber(value reflect.Value, target reflect.Type) (reflect.Value, bool, error) {
+	if !value.IsValid() {
+		return reflect.Value{}, false, nil
+	}
+
+	switch {
+	case isNumeric(value.Kind()) && isNumeric(target.Kind()):
+		converted, err := convertNumber(value, target)
+		return converted, true, err
+	case isNumeric(value.Kind()) && target.Kind() == reflect.String:
+		return reflect.ValueOf(formatNumber(value)).Convert(target), true, nil
+	case value.Kind() == reflect.String && isNumeric(target.Kind()):
+		converted, err := parseNumber(value.String(), target)
+		return converted, true, err
+	}
+	return reflect.Value{}, false, nil
+}
+
+// isNumeric - reports whether kind is an integer or floating point kind
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseNumber - parses text as a number of type target, failing when it is out of range
+func parseNumber(text string, target reflect.Type) (reflect.Value, error) {
+	result := reflect.New(target).Elem()
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, target.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, target.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetFloat(f)
+	default:
+		u, err := strconv.ParseUint(text, 10, target.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetUint(u)
+	}
+	return result, nil
+}
+
+// formatNumber - formats a numeric value as decimal text
+func formatNumber(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits())
+	default:
+		return strconv.FormatUint(value.Uint(), 10)
+	}
+}
+
+// convertNumber - converts between numeric types, failing when the value would be truncated, rounded or
+// overflow
+func convertNumber(value reflect.Value, target reflect.Type) (reflect.Value, error) {
+	result := reflect.New(target).Elem()
+	lossy := fmt.Errorf("%s value %s does not fit in %s", value.Type(), formatNumber(value), target)
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := value.Int()
+		switch target.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if result.OverflowInt(i) {
+				return reflect.Value{}, lossy
+			}
+			result.SetInt(i)
+		case reflect.Float32, reflect.Float64:
+			result.SetFloat(float64(i))
+			if result.Float() >= math.MaxInt64 || int64(result.Float()) != i {
+				return reflect.Value{}, lossy
+			}
+		default:
+			if i < 0 || result.OverflowUint(uint64(i)) {
+				return reflect.Value{}, lossy
+			}
+			result.SetUint(uint64(i))
+		}
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		switch target.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if !fitsFloat(f, target.Bits()) {
+				return reflect.Value{}, lossy
+			}
+			result.SetFloat(f)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// 2^63 is the first float64 above the int64 range
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || result.OverflowInt(int64(f)) {
+				return reflect.Value{}, lossy
+			}
+			result.SetInt(int64(f))
+		default:
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || result.OverflowUint(uint64(f)) {
+				return reflect.Value{}, lossy
+			}
+			result.SetUint(uint64(f))
+		}
+	default:
+		u := value.Uint()
+		switch target.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if u > math.MaxInt64 || result.OverflowInt(int64(u)) {
+				return reflect.Value{}, lossy
+			}
+			result.SetInt(int64(u))
+		case reflect.Float32, reflect.Float64:
+			result.SetFloat(float64(u))
+			if result.Float() >= math.MaxUint64 || uint64(result.Float()) != u {
+				return reflect.Value{}, lossy
+			}
+		default:
+			if result.OverflowUint(u) {
+				return reflect.Value{}, lossy
+			}
+			result.SetUint(u)
+		}
+	}
+	return result, nil
+}
+
+// fitsFloat - reports whether f is held by a float of the given size, exactly or, for float32, as the
+// shortest decimal form of the float32 nearest to it
+func fitsFloat(f float64, bits int) bool {
+	if bits == 64 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return true
+	}
+	if math.Abs(f) > math.MaxFloat32 {
+		return false
+	}
+
+	nearest := float32(f)
+	if float64(nearest) == f {
+		return true
+	}
+	shortest, _ := strconv.ParseFloat(strconv.FormatFloat(float64(nearest), 'g', -1, 32), 64)
+	return shortest == f
+}
@@ -0,0 +1,52 @@
+package compare
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvertNumber(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		target   interface{}
+		expected interface{}
+	}{
+		{int64(-128), int8(0), int8(-128)},
+		{float64(42), int(0), 42},
+		{float64(0.1), float32(0), float32(0.1)},
+		{float64(float32(0.3)), float32(0), float32(0.3)},
+		{math.Inf(1), float32(0), float32(math.Inf(1))},
+		{uint16(7), "", "7"},
+		{float32(0.1), "", "0.1"},
+		{"65535", uint16(0), uint16(65535)},
+	}
+	for _, test := range tests {
+		converted, ok, err := ConvertNumber(reflect.ValueOf(test.value), reflect.TypeOf(test.target))
+		if !ok || err != nil || converted.Interface() != test.expected {
+			t.Errorf("Expected %v to convert to %#v, got %v (%v, %v)", test.value, test.expected, converted, ok, err)
+		}
+	}
+
+	lossy := []struct {
+		value  interface{}
+		target interface{}
+	}{
+		{int64(300), int8(0)},
+		{float64(3.5), int(0)},
+		{-1, uint(0)},
+		{float64(0.1234567891), float32(0)},
+		{float64(1e300), float32(0)},
+		{int64(1<<53 + 1), float64(0)},
+		{"65536", uint16(0)},
+	}
+	for _, test := range lossy {
+		if _, ok, err := ConvertNumber(reflect.ValueOf(test.value), reflect.TypeOf(test.target)); !ok || err == nil {
+			t.Errorf("Expected error converting %v to %T", test.value, test.target)
+		}
+	}
+
+	if _, ok, _ := ConvertNumber(reflect.ValueOf(true), reflect.TypeOf("")); ok {
+		t.Error("Expected a bool not to be handled as a number")
+	}
+}
@@ -0,0 +1,73 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// typeRegistry maps type names to the concrete types ChangesFromJSON can reconstruct
+var typeRegistry sync.Map
+
+// RegisterType registers the dynamic type of value so that ChangesFromJSON can reconstruct
+// TypeChanged values of that type. Register the exact value stored in the interface field,
+// e.g. RegisterType(&EmailNotifier{}) when the field holds pointers.
+func RegisterType(value interface{}) {
+	if value == nil {
+		return
+	}
+	typeRegistry.Store(TypeName(value), reflect.TypeOf(value))
+}
+
+// TypeName - returns the package qualified name of the dynamic type of value, e.g. "*example.com/notify.EmailNotifier"
+func TypeName(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return typeNameOf(reflect.TypeOf(value))
+}
+
+// typeNameOf - returns the package qualified name of a type
+func typeNameOf(t reflect.Type) string {
+	switch {
+	case t.Kind() == reflect.Ptr:
+		return "*" + typeNameOf(t.Elem())
+	case t.Name() != "" && t.PkgPath() != "":
+		return t.PkgPath() + "." + t.Name()
+	default:
+		return t.String()
+	}
+}
+
+// restoreRegistered - converts a generically decoded JSON value back into its registered concrete type
+func restoreRegistered(typeName string, value interface{}) (interface{}, error) {
+	if typeName == "" || value == nil {
+		return value, nil
+	}
+
+	registered, ok := typeRegistry.Load(typeName)
+	if !ok {
+		return value, nil
+	}
+	t := registered.(reflect.Type)
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Kind() == reflect.Ptr {
+		target := reflect.New(t.Elem())
+		if err := json.Unmarshal(data, target.Interface()); err != nil {
+			return nil, fmt.Errorf("cannot restore value of type %s: %w", typeName, err)
+		}
+		return target.Interface(), nil
+	}
+
+	target := reflect.New(t)
+	if err := json.Unmarshal(data, target.Interface()); err != nil {
+		return nil, fmt.Errorf("cannot restore value of type %s: %w", typeName, err)
+	}
+	return target.Elem().Interface(), nil
+}
@@ -0,0 +1,122 @@
+package compare
+
+import (
+	"strings"
+	"testing"
+)
+
+type Notifier interface {
+	Notify(message string) string
+}
+
+type EmailNotifier struct {
+	Address string
+}
+
+func (n *EmailNotifier) Notify(message string) string { return n.Address + ": " + message }
+
+type SMSNotifier struct {
+	Number string
+}
+
+func (n *SMSNotifier) Notify(message string) string { return n.Number + ": " + message }
+
+type Subscription struct {
+	Topic    string
+	Notifier Notifier
+}
+
+func TestCompareStructsDetectsTypeChanged(t *testing.T) {
+	old := Subscription{Topic: "news", Notifier: &EmailNotifier{Address: "a@example.com"}}
+	new := Subscription{Topic: "news", Notifier: &SMSNotifier{Number: "555"}}
+
+	changes, err := CompareStructs(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	notifierChange := findChangeByField(changes, "Notifier")
+	if notifierChange == nil || notifierChange.ChangeType != TypeChanged {
+		t.Fatalf("Type change not detected correctly: %+v", changes)
+	}
+	if !strings.HasSuffix(notifierChange.OldType, "compare.EmailNotifier") || !strings.HasPrefix(notifierChange.OldType, "*") {
+		t.Errorf("Unexpected OldType %q", notifierChange.OldType)
+	}
+	if !strings.HasSuffix(notifierChange.NewType, "compare.SMSNotifier") {
+		t.Errorf("Unexpected NewType %q", notifierChange.NewType)
+	}
+}
+
+func TestCompareStructsKeepsModifiedForSameDynamicType(t *testing.T) {
+	old := Subscription{Notifier: &EmailNotifier{Address: "a@example.com"}}
+	new := Subscription{Notifier: &EmailNotifier{Address: "b@example.com"}}
+
+	changes, err := CompareStructs(old, new)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	notifierChange := findChangeByField(changes, "Notifier")
+	if notifierChange == nil || notifierChange.ChangeType != Modified || notifierChange.NewType != "" {
+		t.Errorf("Expected plain Modified change, got %+v", notifierChange)
+	}
+}
+
+func TestChangesFromJSONRestoresRegisteredTypes(t *testing.T) {
+	RegisterType(&EmailNotifier{})
+	RegisterType(&SMSNotifier{})
+
+	changes, err := CompareStructs(
+		Subscription{Notifier: &EmailNotifier{Address: "a@example.com"}},
+		Subscription{Notifier: &SMSNotifier{Number: "555"}},
+	)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	jsonData, err := ChangesToJSON(changes)
+	if err != nil {
+		t.Fatalf("ChangesToJSON failed: %v", err)
+	}
+
+	restored, err := ChangesFromJSON(jsonData)
+	if err != nil {
+		t.Fatalf("ChangesFromJSON failed: %v", err)
+	}
+
+	notifierChange := findChangeByField(restored, "Notifier")
+	if notifierChange == nil || notifierChange.ChangeType != TypeChanged {
+		t.Fatalf("JSON round trip did not preserve the type change: %+v", restored)
+	}
+
+	sms, ok := notifierChange.NewValue.(*SMSNotifier)
+	if !ok || sms.Number != "555" {
+		t.Errorf("Expected *SMSNotifier new value, got %#v", notifierChange.NewValue)
+	}
+	if _, ok := notifierChange.OldValue.(*EmailNotifier); !ok {
+		t.Errorf("Expected *EmailNotifier old value, got %#v", notifierChange.OldValue)
+	}
+}
+
+func TestChangesFromJSONLeavesUnregisteredTypesGeneric(t *testing.T) {
+	jsonData := []byte(`[{"Field":"Notifier","ChangeType":"type_changed","OldValue":null,"NewValue":{"Number":"1"},"NewType":"*example.com/unknown.Type"}]`)
+
+	restored, err := ChangesFromJSON(jsonData)
+	if err != nil {
+		t.Fatalf("ChangesFromJSON failed: %v", err)
+	}
+
+	if _, ok := restored[0].NewValue.(map[string]interface{}); !ok {
+		t.Errorf("Expected generic map for unregistered type, got %#v", restored[0].NewValue)
+	}
+}
+
+func TestRevertChangesSwapsTypeNames(t *testing.T) {
+	reverted := RevertChanges([]Change{
+		{Field: "Notifier", ChangeType: TypeChanged, OldType: "A", NewType: "B"},
+	})
+
+	if reverted[0].ChangeType != TypeChanged || reverted[0].OldType != "B" || reverted[0].NewType != "A" {
+		t.Errorf("TypeChanged change not reverted correctly: %+v", reverted[0])
+	}
+}
@@ -0,0 +1,335 @@
+// Package configedit writes change lists back into YAML and TOML source text. Only the lines of the
+// changed keys are touched, so comments, key order and formatting elsewhere in the file survive and
+// automated edits produce minimal diffs.
+//
+// The editors are line oriented rather than full parsers. YAML documents must use block style mappings,
+// flow mappings and anchors are not edited through. TOML arrays of tables are not supported.
+package configedit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// edit is a change to a single key of the document
+type edit struct {
+	// path holds the document keys leading to the value
+	path []string
+	// value is the new leaf value, unused when remove is set
+	value  interface{}
+	remove bool
+}
+
+// keyNamer returns the document key of a struct field, and false when the field is not stored
+type keyNamer func(field reflect.StructField) (string, bool)
+
+// textMarshalerType is implemented by values written as a single string, such as time.Time
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// edits - expands changes made to target into edits of leaf values, in struct field order. A change to a
+// nested struct or map becomes one edit per key that differs, in field or sorted key order, so the rest
+// of its block is left alone.
+func edits(target interface{}, changes []compare.Change, name keyNamer) ([]edit, error) {
+	t := reflect.TypeOf(target)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("target must be a struct, got %T", target)
+	}
+
+	// Edits that create keys insert them in the order they come, so keep it independent of the change list
+	sorted := append([]compare.Change(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return slices.Compare(fieldIndex(t, sorted[i].Field), fieldIndex(t, sorted[j].Field)) < 0
+	})
+
+	result := make([]edit, 0, len(changes))
+	for _, change := range sorted {
+		path, fieldType, ok, err := resolve(t, change.Field, name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if change.ChangeType == compare.Deleted {
+			result = append(result, edit{path: path, remove: true})
+			continue
+		}
+
+		oldVal, err := typed(change.OldValue, fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", change.Field, err)
+		}
+		newVal, err := typed(change.NewValue, fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", change.Field, err)
+		}
+		result = expand(result, path, oldVal, newVal, name)
+	}
+	return result, nil
+}
+
+// fieldIndex - returns the index sequence of the fields along a dotted Go field path, ending at the
+// first segment that cannot be resolved
+func fieldIndex(t reflect.Type, fieldPath string) []int {
+	index := make([]int, 0)
+	for _, segment := range strings.Split(fieldPath, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+		field, ok := t.FieldByName(segment)
+		if !ok {
+			break
+		}
+		index = append(index, field.Index...)
+		t = field.Type
+	}
+	return index
+}
+
+// resolve - translates a dotted Go field path into document keys, returning the type of the field
+func resolve(t reflect.Type, fieldPath string, name keyNamer) ([]string, reflect.Type, bool, error) {
+	segments := strings.Split(fieldPath, ".")
+	path := make([]string, 0, len(segments))
+
+	for _, segment := range segments {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, nil, false, fmt.Errorf("cannot descend into %s at %s", t, fieldPath)
+		}
+
+		field, ok := t.FieldByName(segment)
+		if !ok || !field.IsExported() {
+			return nil, nil, false, fmt.Errorf("field %s not found in %s", fieldPath, t)
+		}
+		key, ok := name(field)
+		if !ok {
+			return nil, nil, false, nil
+		}
+		path = append(path, key)
+		t = field.Type
+	}
+	return path, t, true, nil
+}
+
+// expand - appends the edits that turn oldVal into newVal at path
+func expand(result []edit, path []string, oldVal, newVal reflect.Value, name keyNamer) []edit {
+	switch newVal.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		if newVal.IsNil() {
+			return append(result, edit{path: path, remove: true})
+		}
+	}
+
+	for newVal.Kind() == reflect.Ptr || newVal.Kind() == reflect.Interface {
+		newVal = newVal.Elem()
+		oldVal = indirect(oldVal, newVal.Type())
+	}
+
+	switch {
+	case isStruct(newVal.Type()):
+		for i := 0; i < newVal.NumField(); i++ {
+			field := newVal.Type().Field(i)
+			key, ok := name(field)
+			if !field.IsExported() || !ok {
+				continue
+			}
+			if reflect.DeepEqual(oldVal.Field(i).Interface(), newVal.Field(i).Interface()) {
+				continue
+			}
+			result = expand(result, appendPath(path, key), oldVal.Field(i), newVal.Field(i), name)
+		}
+		return result
+
+	case newVal.Kind() == reflect.Map && newVal.Type().Key().Kind() == reflect.String:
+		for _, key := range sortedKeys(newVal) {
+			value := newVal.MapIndex(key)
+			previous := oldVal.MapIndex(key)
+			if previous.IsValid() && reflect.DeepEqual(previous.Interface(), value.Interface()) {
+				continue
+			}
+			if !previous.IsValid() {
+				previous = reflect.Zero(newVal.Type().Elem())
+			}
+			result = expand(result, appendPath(path, key.String()), previous, value, name)
+		}
+
+		for _, key := range sortedKeys(oldVal) {
+			if !newVal.MapIndex(key).IsValid() {
+				result = append(result, edit{path: appendPath(path, key.String()), remove: true})
+			}
+		}
+		return result
+	}
+
+	return append(result, edit{path: path, value: newVal.Interface()})
+}
+
+// sortedKeys - returns the keys of a map with string keys in sorted order, none for an invalid or nil map
+func sortedKeys(m reflect.Value) []reflect.Value {
+	if !m.IsValid() || m.IsNil() {
+		return nil
+	}
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// isStruct - reports whether values of t are edited field by field rather than written as a whole
+func isStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !t.Implements(textMarshalerType) && !reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// indirect - returns the value old points to, or the zero value of t when it points nowhere
+func indirect(old reflect.Value, t reflect.Type) reflect.Value {
+	if (old.Kind() == reflect.Ptr || old.Kind() == reflect.Interface) && !old.IsNil() && old.Elem().Type() == t {
+		return old.Elem()
+	}
+	return reflect.Zero(t)
+}
+
+// appendPath - returns a copy of path with key appended
+func appendPath(path []string, key string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), key)
+}
+
+// typed - converts a change value into t, going through JSON for values decoded from JSON
+func typed(raw interface{}, t reflect.Type) (reflect.Value, error) {
+	if raw == nil {
+		return reflect.Zero(t), nil
+	}
+	if value := reflect.ValueOf(raw); value.Type().AssignableTo(t) {
+		converted := reflect.New(t).Elem()
+		converted.Set(value)
+		return converted, nil
+	}
+
+	target := reflect.New(t)
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := json.Unmarshal(data, target.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot decode value into %s: %w", t, err)
+	}
+	return target.Elem(), nil
+}
+
+// formatScalar - writes booleans and numbers, the syntax YAML and TOML share. Floats are written with
+// the special values of the format and always carry a decimal point.
+func formatScalar(v reflect.Value, inf, nan string) (string, bool) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return nan, true
+		case math.IsInf(f, 1):
+			return inf, true
+		case math.IsInf(f, -1):
+			return "-" + inf, true
+		}
+		text := strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return text, true
+	}
+	return "", false
+}
+
+// textOf - returns the string form of strings and text marshalers
+func textOf(v reflect.Value) (string, bool, error) {
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), true, err
+	}
+	if v.Kind() == reflect.String {
+		return v.String(), true, nil
+	}
+	return "", false, nil
+}
+
+// isList - reports whether a value is written as a sequence
+func isList(v reflect.Value) bool {
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+// document is source text split into lines, remembering its line ending
+type document struct {
+	lines    []string
+	newline  string
+	trailing bool
+}
+
+// newDocument - splits source into lines
+func newDocument(source []byte) *document {
+	d := &document{newline: "\n", trailing: true}
+	if bytes.Contains(source, []byte("\r\n")) {
+		d.newline = "\r\n"
+	}
+
+	text := string(source)
+	if text == "" {
+		return d
+	}
+	d.trailing = strings.HasSuffix(text, d.newline)
+	text = strings.TrimSuffix(text, d.newline)
+	d.lines = strings.Split(text, d.newline)
+	return d
+}
+
+// replace - replaces lines[start:end] with lines
+func (d *document) replace(start, end int, lines ...string) {
+	updated := make([]string, 0, len(d.lines)-(end-start)+len(lines))
+	updated = append(updated, d.lines[:start]...)
+	updated = append(updated, lines...)
+	updated = append(updated, d.lines[end:]...)
+	d.lines = updated
+}
+
+// bytes - joins the lines back into source text
+func (d *document) bytes() []byte {
+	text := strings.Join(d.lines, d.newline)
+	if d.trailing && len(d.lines) > 0 {
+		text += d.newline
+	}
+	return []byte(text)
+}
+
+// keyEqual - matches a document key against the key of a field, falling back to a case-insensitive
+// match as decoders do
+func keyEqual(documentKey, key string) bool {
+	return documentKey == key || strings.EqualFold(documentKey, key)
+}
+
+// commentText - returns a trailing comment ready to append to a value, separated by a space at least
+func commentText(comment string) string {
+	if comment != "" && !strings.HasPrefix(comment, " ") && !strings.HasPrefix(comment, "\t") {
+		return " " + comment
+	}
+	return comment
+}
@@ -0,0 +1,490 @@
+package configedit
+
+import (
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EditTOML writes the changes made to target into TOML source and returns the edited text.
+//
+// Keys are named by toml struct tags, falling back to the field name, and are matched against the
+// document case-insensitively. Changed values are rewritten in place keeping their trailing comment,
+// whether they sit under a [table] header or are written as dotted keys. Missing keys are added after
+// the last key of their table, and a new table is appended when none exists. Deleted fields are
+// removed together with any table nested under them.
+func EditTOML(source []byte, target interface{}, changes []compare.Change) ([]byte, error) {
+	list, err := edits(target, changes, tomlKey)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newDocument(source)
+	for _, e := range list {
+		if err := editTOML(doc, e); err != nil {
+			return nil, fmt.Errorf("key %s: %w", strings.Join(e.path, "."), err)
+		}
+	}
+	return doc.bytes(), nil
+}
+
+// tomlKey - returns the TOML key of a struct field, and false when the field is skipped
+func tomlKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("toml")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// tomlKind distinguishes the entries of a TOML document
+type tomlKind int
+
+const (
+	tomlKeyValue tomlKind = iota
+	tomlTable
+	tomlArrayTable
+)
+
+// tomlEntry is a table header or a key/value pair, which may span several lines
+type tomlEntry struct {
+	kind tomlKind
+	// path is the full key path, the table path for headers
+	path []string
+	// section is the length of the table path a key/value pair is written under
+	section int
+	// inArray is set for entries under an array of tables
+	inArray    bool
+	start, end int
+	// prefix is the line up to the value, including the equals sign and the whitespace after it
+	prefix string
+	value  string
+	// comment is the trailing comment of the last line with the whitespace in front of it
+	comment string
+}
+
+// editTOML - applies a single edit to the document
+func editTOML(doc *document, e edit) error {
+	entries, err := parseTOML(doc.lines)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.kind == tomlArrayTable && hasPrefix(e.path, entry.path) {
+			return fmt.Errorf("arrays of tables are not supported")
+		}
+	}
+
+	if e.remove {
+		removeTOML(doc, entries, e.path)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.kind == tomlKeyValue && !entry.inArray && pathEqual(entry.path, e.path) {
+			text, err := tomlValue(reflect.ValueOf(e.value), entry.value)
+			if err != nil {
+				return err
+			}
+			doc.replace(entry.start, entry.end, entry.prefix+text+commentText(entry.comment))
+			return nil
+		}
+	}
+	return insertTOML(doc, entries, e)
+}
+
+// insertTOML - adds a missing key next to the keys of its table, or in a new table
+func insertTOML(doc *document, entries []tomlEntry, e edit) error {
+	text, err := tomlValue(reflect.ValueOf(e.value), "")
+	if err != nil {
+		return err
+	}
+	parent := e.path[:len(e.path)-1]
+
+	// After the last key written under the same parent, relative to its table
+	last := -1
+	for i, entry := range entries {
+		if entry.kind == tomlKeyValue && !entry.inArray && entry.section <= len(parent) &&
+			len(entry.path) > len(parent) && hasPrefix(entry.path, parent) {
+			last = i
+		}
+	}
+	if last >= 0 {
+		entry := entries[last]
+		doc.replace(entry.end, entry.end, tomlKeyText(e.path[entry.section:])+" = "+text)
+		return nil
+	}
+
+	// Directly under an existing header
+	for _, entry := range entries {
+		if entry.kind == tomlTable && pathEqual(entry.path, parent) {
+			doc.replace(entry.end, entry.end, tomlKeyText(e.path[len(parent):])+" = "+text)
+			return nil
+		}
+	}
+
+	line := tomlKeyText(e.path[len(parent):]) + " = " + text
+	if len(parent) == 0 {
+		// Root keys must come before the first header, and stay clear of comments attached to it
+		at := len(doc.lines)
+		for _, entry := range entries {
+			if entry.kind != tomlKeyValue {
+				at = entry.start
+				break
+			}
+		}
+		for at > 0 && strings.HasPrefix(strings.TrimSpace(doc.lines[at-1]), "#") {
+			at--
+		}
+		lines := []string{line}
+		if at < len(doc.lines) {
+			lines = append(lines, "")
+		}
+		doc.replace(at, at, lines...)
+		return nil
+	}
+
+	lines := []string{"[" + tomlKeyText(parent) + "]", line}
+	if n := len(doc.lines); n > 0 && strings.TrimSpace(doc.lines[n-1]) != "" {
+		lines = append([]string{""}, lines...)
+	}
+	doc.replace(len(doc.lines), len(doc.lines), lines...)
+	return nil
+}
+
+// removeTOML - removes the keys at or below path and the tables nested under it
+func removeTOML(doc *document, entries []tomlEntry, path []string) {
+	removed := make([]bool, len(doc.lines))
+	mark := func(start, end int) {
+		for i := start; i < end; i++ {
+			removed[i] = true
+		}
+	}
+
+	for i, entry := range entries {
+		switch {
+		case entry.kind == tomlKeyValue && !entry.inArray && hasPrefix(entry.path, path):
+			mark(entry.start, entry.end)
+		case entry.kind == tomlTable && hasPrefix(entry.path, path):
+			// The header, its keys and the blank lines after them, leaving comments that lead into
+			// the next table
+			end := entry.end
+			for _, next := range entries[i+1:] {
+				if next.kind != tomlKeyValue {
+					break
+				}
+				end = next.end
+			}
+			for end < len(doc.lines) && strings.TrimSpace(doc.lines[end]) == "" {
+				end++
+			}
+			mark(entry.start, end)
+		}
+	}
+
+	lines := make([]string, 0, len(doc.lines))
+	for i, line := range doc.lines {
+		if !removed[i] {
+			lines = append(lines, line)
+		}
+	}
+	doc.lines = lines
+}
+
+// parseTOML - finds the headers and key/value pairs of a document
+func parseTOML(lines []string) ([]tomlEntry, error) {
+	entries := make([]tomlEntry, 0, len(lines))
+	var section []string
+	inArray := false
+
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			kind, open, closing := tomlTable, "[", "]"
+			if strings.HasPrefix(trimmed, "[[") {
+				kind, open, closing = tomlArrayTable, "[[", "]]"
+			}
+			path, rest, err := parseTOMLKey(trimmed[len(open):])
+			if err != nil || !strings.HasPrefix(rest, closing) {
+				return nil, fmt.Errorf("line %d: invalid table header", i+1)
+			}
+			section, inArray = path, kind == tomlArrayTable
+			entries = append(entries, tomlEntry{kind: kind, path: path, start: i, end: i + 1})
+			continue
+		}
+
+		keys, rest, err := parseTOMLKey(trimmed)
+		if err != nil || !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		equals := len(lines[i]) - len(strings.TrimLeft(lines[i], " \t")) + len(trimmed) - len(rest)
+		valueStart := len(lines[i]) - len(strings.TrimLeft(lines[i][equals+1:], " \t"))
+		end, value, comment := scanTOMLValue(lines, i, valueStart)
+
+		entries = append(entries, tomlEntry{
+			kind:    tomlKeyValue,
+			path:    append(append(make([]string, 0, len(section)+len(keys)), section...), keys...),
+			section: len(section),
+			inArray: inArray,
+			start:   i,
+			end:     end,
+			prefix:  lines[i][:valueStart],
+			value:   value,
+			comment: comment,
+		})
+		i = end - 1
+	}
+	return entries, nil
+}
+
+// parseTOMLKey - reads a dotted key of bare and quoted parts, returning the text that follows it
+func parseTOMLKey(text string) ([]string, string, error) {
+	parts := make([]string, 0, 1)
+	for {
+		text = strings.TrimLeft(text, " \t")
+		if text == "" {
+			return nil, "", fmt.Errorf("missing key")
+		}
+
+		switch text[0] {
+		case '"':
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, "", err
+			}
+			part, _ := strconv.Unquote(quoted)
+			parts = append(parts, part)
+			text = text[len(quoted):]
+		case '\'':
+			closing := strings.IndexByte(text[1:], '\'')
+			if closing < 0 {
+				return nil, "", fmt.Errorf("unterminated key")
+			}
+			parts = append(parts, text[1:closing+1])
+			text = text[closing+2:]
+		default:
+			n := 0
+			for n < len(text) && isBareKeyChar(text[n]) {
+				n++
+			}
+			if n == 0 {
+				return nil, "", fmt.Errorf("invalid key")
+			}
+			parts = append(parts, text[:n])
+			text = text[n:]
+		}
+
+		text = strings.TrimLeft(text, " \t")
+		if !strings.HasPrefix(text, ".") {
+			return parts, text, nil
+		}
+		text = text[1:]
+	}
+}
+
+// scanTOMLValue - finds where the value starting at column col of line start ends, following strings
+// and arrays across lines. It returns the index after the last line, the value text of the first line
+// and the comment of the last line.
+func scanTOMLValue(lines []string, start, col int) (int, string, string) {
+	const (
+		plain = iota
+		basic
+		literal
+		multiBasic
+		multiLiteral
+	)
+	state, depth := plain, 0
+	first := strings.TrimSpace(lines[start][col:])
+
+	for i := start; i < len(lines); i++ {
+		text, comment := lines[i], ""
+		j := 0
+		if i == start {
+			j = col
+		}
+
+		for j < len(text) {
+			rest := text[j:]
+			switch state {
+			case basic, multiBasic:
+				switch {
+				case rest[0] == '\\':
+					j += 2
+					continue
+				case state == multiBasic && strings.HasPrefix(rest, `"""`):
+					state, j = plain, j+3
+					continue
+				case state == basic && rest[0] == '"':
+					state = plain
+				}
+			case literal, multiLiteral:
+				switch {
+				case state == multiLiteral && strings.HasPrefix(rest, "'''"):
+					state, j = plain, j+3
+					continue
+				case state == literal && rest[0] == '\'':
+					state = plain
+				}
+			default:
+				switch {
+				case strings.HasPrefix(rest, `"""`):
+					state, j = multiBasic, j+3
+					continue
+				case strings.HasPrefix(rest, "'''"):
+					state, j = multiLiteral, j+3
+					continue
+				case rest[0] == '"':
+					state = basic
+				case rest[0] == '\'':
+					state = literal
+				case rest[0] == '[' || rest[0] == '{':
+					depth++
+				case rest[0] == ']' || rest[0] == '}':
+					depth--
+				case rest[0] == '#':
+					cut := len(strings.TrimRight(text[:j], " \t"))
+					comment = strings.TrimRight(text[cut:], " \t")
+					if i == start {
+						first = strings.TrimSpace(text[col:cut])
+					}
+					j = len(text)
+					continue
+				}
+			}
+			j++
+		}
+
+		// Single-line strings cannot continue on the next line
+		if state == basic || state == literal {
+			state = plain
+		}
+		if state == plain && depth <= 0 {
+			return i + 1, first, comment
+		}
+	}
+	return len(lines), first, ""
+}
+
+// tomlValue - formats a value, keeping the quoting style of previous for strings
+func tomlValue(v reflect.Value, previous string) (string, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "", fmt.Errorf("TOML has no null value")
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", fmt.Errorf("TOML has no null value")
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), nil
+	}
+	text, ok, err := textOf(v)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return tomlString(text, previous), nil
+	}
+	if text, ok := formatScalar(v, "inf", "nan"); ok {
+		return text, nil
+	}
+
+	if isList(v) {
+		items := make([]string, v.Len())
+		for i := range items {
+			item, err := tomlValue(v.Index(i), "")
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("cannot write %s as a TOML value", v.Type())
+}
+
+// tomlString - writes a literal string when the previous value was one and s allows it, and a basic
+// string otherwise
+func tomlString(s, previous string) string {
+	if strings.HasPrefix(previous, "'") && !strings.HasPrefix(previous, "'''") &&
+		!strings.Contains(s, "'") && isPrintable(s) {
+		return "'" + s + "'"
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlKeyText - writes a dotted key, quoting parts that are not bare keys
+func tomlKeyText(path []string) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = part
+		if part == "" || strings.IndexFunc(part, func(r rune) bool { return r > 0x7f || !isBareKeyChar(byte(r)) }) >= 0 {
+			parts[i] = tomlString(part, "")
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// isBareKeyChar - reports whether c may appear in an unquoted key
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// hasPrefix - reports whether path starts with prefix, matching keys like keyEqual
+func hasPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if !keyEqual(path[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// pathEqual - reports whether two key paths are the same
+func pathEqual(a, b []string) bool {
+	return len(a) == len(b) && hasPrefix(a, b)
+}
@@ -0,0 +1,114 @@
+package configedit
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"testing"
+)
+
+const tomlSource = `# Service configuration
+name = 'api'   # service name
+tags = [
+  "a", # first
+  "b",
+]
+
+[server]
+host = "localhost"
+port = 8080 # default port
+tls.enabled = false
+
+# Labels applied to metrics
+[labels]
+team = "core"
+tier = "1"
+`
+
+func TestEditTOMLPreservesComments(t *testing.T) {
+	old := testConfig()
+	updated := testConfig()
+	updated.Name = "api-v2"
+	updated.Server.Host = "0.0.0.0"
+	updated.Server.Port = 9090
+	updated.Server.TLS = TLS{Enabled: true, Cert: "/etc/cert.pem"}
+	updated.Tags = []string{"a", "b", "c"}
+	updated.Limits = []int{1, 2, 3}
+	updated.Owner = "ops \"platform\""
+	updated.Labels = map[string]string{"team": "core"}
+
+	changes, err := compare.CompareStructs(old, updated)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+	result, err := EditTOML([]byte(tomlSource), updated, changes)
+	if err != nil {
+		t.Fatalf("EditTOML failed: %v", err)
+	}
+
+	expected := `# Service configuration
+name = 'api-v2'   # service name
+tags = ["a", "b", "c"]
+limits = [1, 2, 3]
+owner = "ops \"platform\""
+
+[server]
+host = "0.0.0.0"
+port = 9090 # default port
+tls.enabled = true
+tls.cert = "/etc/cert.pem"
+
+# Labels applied to metrics
+[labels]
+team = "core"
+`
+	if string(result) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestEditTOMLAddsAndRemovesTables(t *testing.T) {
+	changes := []compare.Change{
+		{Field: "Labels", ChangeType: compare.Deleted, OldValue: map[string]string{"team": "core"}},
+		{Field: "Server.TLS.Cert", ChangeType: compare.Added, OldValue: "", NewValue: "c.pem"},
+	}
+	source := "name = \"x\"\n\n[labels]\nteam = \"core\"\n\n[labels.extra]\nk = 1\n"
+
+	result, err := EditTOML([]byte(source), Config{}, changes)
+	if err != nil {
+		t.Fatalf("EditTOML failed: %v", err)
+	}
+
+	expected := "name = \"x\"\n\n[server.tls]\ncert = \"c.pem\"\n"
+	if string(result) != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, result)
+	}
+}
+
+func TestEditTOMLInsertsKeysInOrder(t *testing.T) {
+	// Changes arrive in any order, new keys still follow field order and sorted map keys
+	changes := []compare.Change{
+		{Field: "Labels", ChangeType: compare.Modified, OldValue: map[string]string{},
+			NewValue: map[string]string{"zone": "b", "env": "prod", "tier": "1"}},
+		{Field: "Owner", ChangeType: compare.Added, OldValue: "", NewValue: "ops"},
+		{Field: "Limits", ChangeType: compare.Added, NewValue: []int{1}},
+	}
+	source := "name = \"x\"\n\n[labels]\n"
+
+	for i := 0; i < 20; i++ {
+		result, err := EditTOML([]byte(source), Config{}, changes)
+		if err != nil {
+			t.Fatalf("EditTOML failed: %v", err)
+		}
+
+		expected := "name = \"x\"\nlimits = [1]\nowner = \"ops\"\n\n[labels]\nenv = \"prod\"\ntier = \"1\"\nzone = \"b\"\n"
+		if string(result) != expected {
+			t.Fatalf("Expected\n%q\ngot\n%q", expected, result)
+		}
+	}
+}
+
+func TestEditTOMLRejectsArraysOfTables(t *testing.T) {
+	changes := []compare.Change{{Field: "Server.Port", ChangeType: compare.Modified, OldValue: 1, NewValue: 2}}
+	if _, err := EditTOML([]byte("[[server]]\nport = 1\n"), Config{}, changes); err == nil {
+		t.Error("Expected error when editing inside an array of tables")
+	}
+}
@@ -0,0 +1,431 @@
+package configedit
+
+import (
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EditYAML writes the changes made to target into YAML source and returns the edited text.
+//
+// Keys are named by yaml struct tags, falling back to the lowercased field name. Changed scalars are
+// rewritten in place keeping their trailing comment and quoting style, block sequences stay block
+// sequences, and nested struct or map changes only touch the keys that differ. Missing keys are
+// appended to the end of their mapping, creating parent mappings as needed, and deleted fields are
+// removed together with their nested block.
+func EditYAML(source []byte, target interface{}, changes []compare.Change) ([]byte, error) {
+	list, err := edits(target, changes, yamlKey)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newDocument(source)
+	for _, e := range list {
+		if err := editYAML(doc, e); err != nil {
+			return nil, fmt.Errorf("key %s: %w", strings.Join(e.path, "."), err)
+		}
+	}
+	return doc.bytes(), nil
+}
+
+// yamlKey - returns the YAML key of a struct field, and false when the field is skipped
+func yamlKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, true
+}
+
+// yamlLine is a `key: value # comment` line of a block mapping
+type yamlLine struct {
+	indent int
+	key    string
+	// prefix is the line up to and including the colon
+	prefix string
+	value  string
+	// comment is the trailing comment with the whitespace in front of it
+	comment string
+}
+
+// text - returns the line with its value replaced, keeping the comment
+func (l yamlLine) text(value string) string {
+	line := l.prefix
+	if value != "" {
+		line += " " + value
+	}
+	return line + commentText(l.comment)
+}
+
+// editYAML - applies a single edit to the document
+func editYAML(doc *document, e edit) error {
+	start, end, parentIndent := 0, len(doc.lines), -1
+
+	for i, key := range e.path {
+		index, line, childIndent, found := findYAMLKey(doc.lines, start, end, parentIndent, key)
+		if !found {
+			if e.remove {
+				return nil
+			}
+			return insertYAML(doc, start, end, parentIndent, childIndent, e.path[i:], e.value)
+		}
+		blockEnd := yamlBlockEnd(doc.lines, index, line.indent)
+
+		if i == len(e.path)-1 {
+			if e.remove {
+				doc.replace(index, blockEnd)
+				return nil
+			}
+			return setYAML(doc, index, blockEnd, line, e.value)
+		}
+
+		// Descend into the nested mapping, turning an empty placeholder value into one
+		switch line.value {
+		case "":
+			if first := firstContent(doc.lines, index+1, blockEnd); first >= 0 && isSequenceItem(doc.lines[first]) {
+				return fmt.Errorf("cannot edit inside the sequence at %s", key)
+			}
+		case "~", "null", "Null", "NULL", "{}":
+			doc.lines[index] = line.text("")
+		default:
+			return fmt.Errorf("cannot edit inside the value %s of %s", line.value, key)
+		}
+		start, end, parentIndent = index+1, blockEnd, line.indent
+	}
+	return nil
+}
+
+// setYAML - replaces the value of the key at index, whose nested block ends at end
+func setYAML(doc *document, index, end int, line yamlLine, value interface{}) error {
+	v := reflect.ValueOf(value)
+
+	// A block sequence is rewritten as a block sequence at the same indentation
+	if value != nil && isList(v) && v.Len() > 0 && line.value == "" {
+		if first := firstContent(doc.lines, index+1, end); first >= 0 && isSequenceItem(doc.lines[first]) {
+			pad := doc.lines[first][:len(doc.lines[first])-len(strings.TrimLeft(doc.lines[first], " "))]
+			items := make([]string, v.Len())
+			for i := range items {
+				text, err := yamlScalar(v.Index(i), "", false)
+				if err != nil {
+					return err
+				}
+				items[i] = pad + "- " + text
+			}
+			doc.replace(index+1, end, items...)
+			return nil
+		}
+	}
+
+	text, err := yamlValue(v, line.value)
+	if err != nil {
+		return err
+	}
+	doc.lines[index] = line.text(text)
+	doc.replace(index+1, end)
+	return nil
+}
+
+// insertYAML - adds the missing keys of path to the mapping between start and end
+func insertYAML(doc *document, start, end, parentIndent, childIndent int, path []string, value interface{}) error {
+	indent := childIndent
+	if indent < 0 {
+		indent = parentIndent + 2
+		if parentIndent < 0 {
+			indent = 0
+		}
+	}
+
+	// New keys go after the last line of the mapping, ahead of any comments that follow it
+	at := start
+	if parentIndent < 0 {
+		at = end
+	}
+	if last := lastContent(doc.lines, start, end); last >= 0 {
+		at = last + 1
+	}
+
+	lines := make([]string, 0, len(path))
+	for i, key := range path {
+		line := strings.Repeat(" ", indent+2*i) + yamlKeyText(key) + ":"
+		if i == len(path)-1 {
+			text, err := yamlValue(reflect.ValueOf(value), "")
+			if err != nil {
+				return err
+			}
+			line += " " + text
+		}
+		lines = append(lines, line)
+	}
+	doc.replace(at, at, lines...)
+	return nil
+}
+
+// findYAMLKey - finds key among the entries of the mapping between start and end, returning the
+// indentation of the entries even when the key is missing
+func findYAMLKey(lines []string, start, end, parentIndent int, key string) (int, yamlLine, int, bool) {
+	childIndent := -1
+	for i := start; i < end; i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		indent := len(lines[i]) - len(trimmed)
+		if !isYAMLContent(trimmed) || indent <= parentIndent {
+			continue
+		}
+		if childIndent < 0 {
+			childIndent = indent
+		}
+		if indent != childIndent {
+			continue
+		}
+
+		if line, ok := parseYAMLLine(lines[i]); ok && keyEqual(line.key, key) {
+			return i, line, childIndent, true
+		}
+	}
+	return -1, yamlLine{}, childIndent, false
+}
+
+// yamlBlockEnd - returns the index after the last line nested under the key at index
+func yamlBlockEnd(lines []string, index, indent int) int {
+	end := index + 1
+	for i := index + 1; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if trimmed == "" {
+			continue
+		}
+
+		lineIndent := len(lines[i]) - len(trimmed)
+		switch {
+		case lineIndent > indent:
+			end = i + 1
+		case !isYAMLContent(trimmed):
+			continue
+		case lineIndent == indent && isSequenceItem(trimmed):
+			// A sequence may sit at the indentation of its key
+			end = i + 1
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// parseYAMLLine - splits a mapping entry into key, value and comment
+func parseYAMLLine(line string) (yamlLine, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := len(line) - len(trimmed)
+	if !isYAMLContent(trimmed) || isSequenceItem(trimmed) {
+		return yamlLine{}, false
+	}
+
+	var key string
+	var keyEnd int
+	switch trimmed[0] {
+	case '"':
+		quoted, err := strconv.QuotedPrefix(trimmed)
+		if err != nil {
+			return yamlLine{}, false
+		}
+		key, _ = strconv.Unquote(quoted)
+		keyEnd = len(quoted)
+	case '\'':
+		closing := singleQuoteEnd(trimmed)
+		if closing < 0 {
+			return yamlLine{}, false
+		}
+		key = strings.ReplaceAll(trimmed[1:closing], "''", "'")
+		keyEnd = closing + 1
+	default:
+		keyEnd = strings.Index(trimmed, ": ")
+		if keyEnd < 0 && strings.HasSuffix(trimmed, ":") {
+			keyEnd = len(trimmed) - 1
+		}
+		if keyEnd < 0 || strings.Contains(trimmed[:keyEnd], " #") {
+			return yamlLine{}, false
+		}
+		key = strings.TrimSpace(trimmed[:keyEnd])
+	}
+
+	rest := strings.TrimLeft(trimmed[keyEnd:], " ")
+	if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ' && rest[1] != '\t') {
+		return yamlLine{}, false
+	}
+	colon := len(line) - len(rest)
+
+	value, comment := splitYAMLComment(rest[1:])
+	return yamlLine{indent: indent, key: key, prefix: line[:colon+1], value: value, comment: comment}, true
+}
+
+// splitYAMLComment - separates a value from its trailing comment, which keeps the spacing before it
+func splitYAMLComment(text string) (string, string) {
+	value := strings.TrimSpace(text)
+	var quote byte
+	tracked := strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") ||
+		strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'")
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case tracked && (c == '"' || c == '\''):
+			quote = c
+		case c == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t'):
+			cut := len(strings.TrimRight(value[:i], " \t"))
+			return value[:cut], value[cut:]
+		}
+	}
+	return value, ""
+}
+
+// singleQuoteEnd - returns the index of the quote closing a single-quoted scalar, -1 if there is none
+func singleQuoteEnd(text string) int {
+	for i := 1; i < len(text); i++ {
+		if text[i] != '\'' {
+			continue
+		}
+		if i+1 < len(text) && text[i+1] == '\'' {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// yamlValue - formats a scalar or a flow sequence, keeping the quoting style of previous
+func yamlValue(v reflect.Value, previous string) (string, error) {
+	if !v.IsValid() || !isList(v) {
+		return yamlScalar(v, previous, false)
+	}
+
+	items := make([]string, v.Len())
+	for i := range items {
+		text, err := yamlScalar(v.Index(i), "", true)
+		if err != nil {
+			return "", err
+		}
+		items[i] = text
+	}
+	return "[" + strings.Join(items, ", ") + "]", nil
+}
+
+// yamlScalar - formats a single value, quoting strings inside flow collections where needed
+func yamlScalar(v reflect.Value, previous string, flow bool) (string, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "null", nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "null", nil
+	}
+
+	text, ok, err := textOf(v)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return yamlString(text, previous, flow), nil
+	}
+	if text, ok := formatScalar(v, ".inf", ".nan"); ok {
+		return text, nil
+	}
+	return "", fmt.Errorf("cannot write %s as a YAML scalar", v.Type())
+}
+
+// yamlString - writes s plain when that reads back as the same string, and quoted otherwise
+func yamlString(s, previous string, flow bool) string {
+	switch {
+	case strings.HasPrefix(previous, "'") && isPrintable(s):
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case strings.HasPrefix(previous, "\"") || !yamlPlain(s) || (flow && strings.ContainsAny(s, ",[]{}")):
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// yamlKeyText - writes a key, quoting it when it is not a plain scalar
+func yamlKeyText(key string) string {
+	if yamlPlain(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// yamlPlain - reports whether s can be written as a plain scalar and still decode as the same string
+func yamlPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || !isPrintable(s) {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`.") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// isPrintable - reports whether s fits on a single line without escapes
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// isYAMLContent - reports whether a trimmed line holds data rather than a comment or document marker
+func isYAMLContent(trimmed string) bool {
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "%") &&
+		trimmed != "---" && !strings.HasPrefix(trimmed, "--- ") && trimmed != "..."
+}
+
+// isSequenceItem - reports whether a line is an entry of a block sequence
+func isSequenceItem(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+}
+
+// firstContent - returns the index of the first content line between start and end, -1 if there is none
+func firstContent(lines []string, start, end int) int {
+	for i := start; i < end; i++ {
+		if isYAMLContent(strings.TrimSpace(lines[i])) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastContent - returns the index of the last content line between start and end, -1 if there is none
+func lastContent(lines []string, start, end int) int {
+	for i := end - 1; i >= start; i-- {
+		if isYAMLContent(strings.TrimSpace(lines[i])) {
+			return i
+		}
+	}
+	return -1
+}
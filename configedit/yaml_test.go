@@ -0,0 +1,132 @@
+package configedit
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"testing"
+)
+
+type TLS struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled"`
+	Cert    string `yaml:"cert,omitempty" toml:"cert"`
+}
+
+type Server struct {
+	Host string `yaml:"host" toml:"host"`
+	Port int    `yaml:"port" toml:"port"`
+	TLS  TLS    `yaml:"tls" toml:"tls"`
+}
+
+type Config struct {
+	Name   string            `yaml:"name" toml:"name"`
+	Server Server            `yaml:"server" toml:"server"`
+	Tags   []string          `yaml:"tags" toml:"tags"`
+	Limits []int             `yaml:"limits" toml:"limits"`
+	Owner  string            `yaml:"owner,omitempty" toml:"owner"`
+	Labels map[string]string `yaml:"labels,omitempty" toml:"labels"`
+	Secret string            `yaml:"-" toml:"-"`
+}
+
+func testConfig() Config {
+	return Config{
+		Name:   "api",
+		Server: Server{Host: "localhost", Port: 8080},
+		Tags:   []string{"a", "b"},
+		Limits: []int{1, 2},
+		Labels: map[string]string{"team": "core", "tier": "1"},
+	}
+}
+
+const yamlSource = `# Service configuration
+name: api   # service name
+server:
+  host: "localhost"
+  port: 8080 # default port
+
+  # TLS settings
+  tls:
+    enabled: false
+tags:
+  - a
+  - b
+limits: [1, 2]
+labels:
+  team: core
+  tier: '1'
+
+# trailing comment
+`
+
+func TestEditYAMLPreservesComments(t *testing.T) {
+	old := testConfig()
+	updated := testConfig()
+	updated.Name = "api-v2"
+	updated.Server.Host = "0.0.0.0"
+	updated.Server.Port = 9090
+	updated.Server.TLS = TLS{Enabled: true, Cert: "/etc/cert.pem"}
+	updated.Tags = []string{"a", "b", "c"}
+	updated.Limits = []int{1, 2, 3}
+	updated.Owner = "ops: platform"
+	updated.Labels = map[string]string{"team": "core", "tier": "2"}
+	updated.Secret = "ignored"
+
+	changes, err := compare.CompareStructs(old, updated)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+	result, err := EditYAML([]byte(yamlSource), updated, changes)
+	if err != nil {
+		t.Fatalf("EditYAML failed: %v", err)
+	}
+
+	expected := `# Service configuration
+name: api-v2   # service name
+server:
+  host: "0.0.0.0"
+  port: 9090 # default port
+
+  # TLS settings
+  tls:
+    enabled: true
+    cert: /etc/cert.pem
+tags:
+  - a
+  - b
+  - c
+limits: [1, 2, 3]
+labels:
+  team: core
+  tier: '2'
+owner: "ops: platform"
+
+# trailing comment
+`
+	if string(result) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestEditYAMLRemovesAndCreatesBlocks(t *testing.T) {
+	changes := []compare.Change{
+		{Field: "Tags", ChangeType: compare.Deleted, OldValue: []string{"a", "b"}},
+		{Field: "Labels", ChangeType: compare.Modified, OldValue: map[string]string{"team": "core", "tier": "1"}, NewValue: map[string]string{"team": "core"}},
+		{Field: "Server.TLS.Cert", ChangeType: compare.Added, OldValue: "", NewValue: "yes"},
+	}
+	source := "server:\n  host: x\nlabels:\n  team: core\n  tier: 1\ntags:\n- a\n- b\nlimits: []\n"
+
+	result, err := EditYAML([]byte(source), Config{}, changes)
+	if err != nil {
+		t.Fatalf("EditYAML failed: %v", err)
+	}
+
+	expected := "server:\n  host: x\n  tls:\n    cert: \"yes\"\nlabels:\n  team: core\nlimits: []\n"
+	if string(result) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestEditYAMLRejectsFlowMappings(t *testing.T) {
+	changes := []compare.Change{{Field: "Server.Port", ChangeType: compare.Modified, OldValue: 1, NewValue: 2}}
+	if _, err := EditYAML([]byte("server: {port: 1}\n"), Config{}, changes); err == nil {
+		t.Error("Expected error when editing inside a flow mapping")
+	}
+}
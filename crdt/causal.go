@@ -0,0 +1,162 @@
+package crdt
+
+import (
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"sync"
+)
+
+// CausalOrder returns how change set a relates causally to change set b, based on their vector clocks
+func CausalOrder(a, b compare.ChangeSet) Ordering {
+	return VectorClock(a.Clock).Compare(VectorClock(b.Clock))
+}
+
+// CausalBuffer delivers change sets from other replicas in causal order. A change set is held back
+// until every change set it depends on has been delivered, and duplicates are dropped.
+//
+// The deliver callback runs outside the buffer's lock and one change set at a time, so it may call
+// back into the buffer. Change sets it receives are delivered after it returns.
+type CausalBuffer struct {
+	mu         sync.Mutex
+	replica    string
+	delivered  VectorClock
+	pending    []compare.ChangeSet
+	delivering bool
+	deliver    func(compare.ChangeSet) error
+}
+
+// NewCausalBuffer creates the buffer of the local replica, calling deliver for each change set once
+// its dependencies have been delivered
+func NewCausalBuffer(replica string, deliver func(compare.ChangeSet) error) *CausalBuffer {
+	return &CausalBuffer{replica: replica, delivered: make(VectorClock), deliver: deliver}
+}
+
+// Stamp records a local change set as the next event of this replica and attaches the replica ID and
+// vector clock to it, ready to be sent to the other replicas
+func (b *CausalBuffer) Stamp(cs *compare.ChangeSet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.delivered = b.delivered.Tick(b.replica)
+	cs.Replica = b.replica
+	cs.Clock = b.delivered.Copy()
+}
+
+// Receive accepts a change set from another replica and delivers it, together with any buffered
+// change sets it unblocks, as soon as its dependencies are satisfied. A change set whose delivery
+// failed stays buffered and is retried by the next Receive. When another call is already delivering,
+// Receive leaves the delivery to it and returns nil.
+func (b *CausalBuffer) Receive(cs compare.ChangeSet) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cs.Replica == "" || cs.Clock == nil {
+		return fmt.Errorf("change set %s carries no vector clock", cs.ID)
+	}
+	if b.isDelivered(cs) {
+		return nil
+	}
+	if !b.isPending(cs) {
+		b.pending = append(b.pending, cs)
+	}
+
+	if b.delivering {
+		return nil
+	}
+	b.delivering = true
+	defer func() { b.delivering = false }()
+
+	// Keep delivering until no buffered change set becomes ready
+	for {
+		next, ok := b.nextReady()
+		if !ok {
+			return nil
+		}
+		if err := b.deliverUnlocked(next); err != nil {
+			return fmt.Errorf("cannot deliver change set %s: %w", next.ID, err)
+		}
+
+		b.delivered = b.delivered.Merge(VectorClock{next.Replica: next.Clock[next.Replica]})
+		for i, p := range b.pending {
+			if sameEvent(p, next) {
+				b.pending = append(b.pending[:i], b.pending[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// nextReady - drops buffered duplicates of delivered change sets and returns the first one that is ready,
+// the caller must hold the lock
+func (b *CausalBuffer) nextReady() (compare.ChangeSet, bool) {
+	for i := 0; i < len(b.pending); i++ {
+		next := b.pending[i]
+		if b.isDelivered(next) {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			i--
+			continue
+		}
+		if b.isReady(next) {
+			return next, true
+		}
+	}
+	return compare.ChangeSet{}, false
+}
+
+// deliverUnlocked - calls deliver with the lock released, the caller must hold the lock
+func (b *CausalBuffer) deliverUnlocked(cs compare.ChangeSet) error {
+	b.mu.Unlock()
+	defer b.mu.Lock()
+	return b.deliver(cs)
+}
+
+// Delivered returns the vector clock of everything delivered or stamped so far
+func (b *CausalBuffer) Delivered() VectorClock {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.delivered.Copy()
+}
+
+// Pending returns the number of change sets waiting for their dependencies
+func (b *CausalBuffer) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// isDelivered - reports whether cs has already been delivered, the caller must hold the lock
+func (b *CausalBuffer) isDelivered(cs compare.ChangeSet) bool {
+	return cs.Clock[cs.Replica] <= b.delivered[cs.Replica]
+}
+
+// isPending - reports whether cs is already buffered, the caller must hold the lock
+func (b *CausalBuffer) isPending(cs compare.ChangeSet) bool {
+	for _, p := range b.pending {
+		if sameEvent(p, cs) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameEvent - reports whether two change sets are the same event, by ID or by replica and counter
+func sameEvent(a, b compare.ChangeSet) bool {
+	if a.ID != "" && a.ID == b.ID {
+		return true
+	}
+	return a.Replica == b.Replica && a.Clock[a.Replica] == b.Clock[b.Replica]
+}
+
+// isReady - reports whether cs is the next event of its replica and all its other dependencies
+// have been delivered, the caller must hold the lock
+func (b *CausalBuffer) isReady(cs compare.ChangeSet) bool {
+	if cs.Clock[cs.Replica] != b.delivered[cs.Replica]+1 {
+		return false
+	}
+	for replica, count := range cs.Clock {
+		if replica != cs.Replica && count > b.delivered[replica] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package crdt
+
+import (
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"testing"
+)
+
+func TestCausalOrderOfChangeSets(t *testing.T) {
+	a := NewCausalBuffer("a", nil)
+	b := NewCausalBuffer("b", func(compare.ChangeSet) error { return nil })
+
+	first := compare.ChangeSet{ID: "1"}
+	a.Stamp(&first)
+	b.Receive(first)
+
+	second := compare.ChangeSet{ID: "2"}
+	b.Stamp(&second)
+	concurrent := compare.ChangeSet{ID: "3"}
+	a.Stamp(&concurrent)
+
+	if got := CausalOrder(first, second); got != Before {
+		t.Errorf("Expected first before second, got %s", got)
+	}
+	if got := CausalOrder(second, first); got != After {
+		t.Errorf("Expected second after first, got %s", got)
+	}
+	if got := CausalOrder(second, concurrent); got != Concurrent {
+		t.Errorf("Expected second and third to be concurrent, got %s", got)
+	}
+}
+
+func TestCausalBufferHoldsBackUntilDependenciesArrive(t *testing.T) {
+	a := NewCausalBuffer("a", nil)
+	b := NewCausalBuffer("b", func(compare.ChangeSet) error { return nil })
+
+	// a makes two changes, b reacts to the second one
+	a1 := compare.ChangeSet{ID: "a1"}
+	a.Stamp(&a1)
+	a2 := compare.ChangeSet{ID: "a2"}
+	a.Stamp(&a2)
+	b.Receive(a1)
+	b.Receive(a2)
+	b1 := compare.ChangeSet{ID: "b1"}
+	b.Stamp(&b1)
+
+	var delivered []string
+	c := NewCausalBuffer("c", func(cs compare.ChangeSet) error {
+		delivered = append(delivered, cs.ID)
+		return nil
+	})
+
+	// Deliver in the worst possible order, with a duplicate
+	for _, cs := range []compare.ChangeSet{b1, a2, b1, a1} {
+		if err := c.Receive(cs); err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if cs.ID == "a2" && c.Pending() != 2 {
+			t.Errorf("Expected b1 and a2 to be held back, %d pending", c.Pending())
+		}
+	}
+
+	if len(delivered) != 3 || delivered[0] != "a1" || delivered[1] != "a2" || delivered[2] != "b1" {
+		t.Errorf("Expected causal delivery a1, a2, b1, got %v", delivered)
+	}
+	if c.Pending() != 0 {
+		t.Errorf("Expected nothing pending, got %d", c.Pending())
+	}
+	if clock := c.Delivered(); clock["a"] != 2 || clock["b"] != 1 {
+		t.Errorf("Unexpected delivered clock %v", clock)
+	}
+}
+
+func TestCausalBufferRejectsUnstampedChangeSets(t *testing.T) {
+	c := NewCausalBuffer("c", func(compare.ChangeSet) error { return nil })
+	if err := c.Receive(compare.ChangeSet{ID: "x"}); err == nil {
+		t.Error("Expected error for a change set without a vector clock")
+	}
+}
+
+func TestCausalBufferKeepsChangeSetWhenDeliveryFails(t *testing.T) {
+	failing := errors.New("apply failed")
+	attempts := 0
+	c := NewCausalBuffer("c", func(compare.ChangeSet) error {
+		attempts++
+		if attempts == 1 {
+			return failing
+		}
+		return nil
+	})
+
+	a := NewCausalBuffer("a", nil)
+	cs := compare.ChangeSet{ID: "a1"}
+	a.Stamp(&cs)
+
+	if err := c.Receive(cs); !errors.Is(err, failing) {
+		t.Fatalf("Expected delivery error, got %v", err)
+	}
+	if err := c.Receive(cs); err != nil || c.Delivered()["a"] != 1 {
+		t.Errorf("Expected redelivery to succeed, got %v", err)
+	}
+}
+
+func TestCausalBufferDeliverMayCallBackIntoBuffer(t *testing.T) {
+	a := NewCausalBuffer("a", nil)
+	a1 := compare.ChangeSet{ID: "a1"}
+	a.Stamp(&a1)
+	a2 := compare.ChangeSet{ID: "a2"}
+	a.Stamp(&a2)
+
+	var c *CausalBuffer
+	var delivered []string
+	c = NewCausalBuffer("c", func(cs compare.ChangeSet) error {
+		delivered = append(delivered, cs.ID)
+
+		// Reacting to a delivery stamps a local change set and receives more
+		reply := compare.ChangeSet{ID: "reply-" + cs.ID}
+		c.Stamp(&reply)
+		if cs.ID == "a1" {
+			c.Receive(a2)
+		}
+		return nil
+	})
+
+	if err := c.Receive(a1); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if len(delivered) != 2 || delivered[1] != "a2" {
+		t.Errorf("Expected a1 then a2 delivered, got %v", delivered)
+	}
+	if clock := c.Delivered(); clock["a"] != 2 || clock["c"] != 2 {
+		t.Errorf("Unexpected delivered clock %v", clock)
+	}
+}
+
+func TestCausalBufferDoesNotBufferDuplicates(t *testing.T) {
+	failing := errors.New("apply failed")
+	c := NewCausalBuffer("c", func(compare.ChangeSet) error { return failing })
+
+	a := NewCausalBuffer("a", nil)
+	cs := compare.ChangeSet{ID: "a1"}
+	a.Stamp(&cs)
+
+	c.Receive(cs)
+	c.Receive(cs)
+	if c.Pending() != 1 {
+		t.Errorf("Expected a single buffered change set, got %d", c.Pending())
+	}
+}
@@ -0,0 +1,77 @@
+package crdt
+
+import (
+	"sync"
+	"time"
+)
+
+// Timestamp is a hybrid logical clock reading: physical time in nanoseconds plus a logical counter
+// for events within the same nanosecond, tagged with the replica that produced it. Timestamps from
+// different replicas are totally ordered, with the replica ID breaking exact ties.
+type Timestamp struct {
+	WallTime int64  `json:"wall_time"`
+	Logical  uint32 `json:"logical"`
+	Replica  string `json:"replica"`
+}
+
+// Less reports whether t happened before other
+func (t Timestamp) Less(other Timestamp) bool {
+	if t.WallTime != other.WallTime {
+		return t.WallTime < other.WallTime
+	}
+	if t.Logical != other.Logical {
+		return t.Logical < other.Logical
+	}
+	return t.Replica < other.Replica
+}
+
+// IsZero reports whether t is the zero timestamp, which is before every real reading
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// Clock is a hybrid logical clock for one replica. Its readings never go backwards, even when the
+// physical clock does, and stay ahead of every timestamp it has observed from other replicas.
+type Clock struct {
+	mu      sync.Mutex
+	replica string
+	last    Timestamp
+	now     func() time.Time
+}
+
+// NewClock creates a clock for replica
+func NewClock(replica string) *Clock {
+	return &Clock{replica: replica, last: Timestamp{Replica: replica}, now: time.Now}
+}
+
+// Now returns a new timestamp for a local event
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixNano()
+	if wall > c.last.WallTime {
+		c.last = Timestamp{WallTime: wall, Replica: c.replica}
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// Observe advances the clock past a timestamp received from another replica
+func (c *Clock) Observe(remote Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixNano()
+	switch {
+	case wall > c.last.WallTime && wall > remote.WallTime:
+		c.last = Timestamp{WallTime: wall, Replica: c.replica}
+	case remote.WallTime > c.last.WallTime:
+		c.last = Timestamp{WallTime: remote.WallTime, Logical: remote.Logical + 1, Replica: c.replica}
+	case remote.WallTime == c.last.WallTime:
+		c.last.Logical = max(c.last.Logical, remote.Logical) + 1
+	default:
+		c.last.Logical++
+	}
+}
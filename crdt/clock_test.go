@@ -0,0 +1,59 @@
+package crdt
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock returns a clock for replica whose physical time only moves when the returned function is called
+func fixedClock(replica string, start time.Time) (*Clock, func(time.Duration)) {
+	now := start
+	clock := NewClock(replica)
+	clock.now = func() time.Time { return now }
+	return clock, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestClockNowIsMonotonic(t *testing.T) {
+	clock, advance := fixedClock("a", time.Unix(100, 0))
+
+	first := clock.Now()
+	second := clock.Now()
+	if !first.Less(second) || second.Logical != first.Logical+1 {
+		t.Errorf("Expected logical counter to advance within the same wall time: %+v %+v", first, second)
+	}
+
+	// Physical clock going backwards must not move the clock back
+	advance(-time.Second)
+	third := clock.Now()
+	if !second.Less(third) {
+		t.Errorf("Expected clock to stay monotonic when wall time goes back: %+v %+v", second, third)
+	}
+
+	advance(2 * time.Second)
+	fourth := clock.Now()
+	if fourth.Logical != 0 || !third.Less(fourth) {
+		t.Errorf("Expected logical counter to reset when wall time moves forward: %+v", fourth)
+	}
+}
+
+func TestClockObserveMovesPastRemote(t *testing.T) {
+	clock, _ := fixedClock("a", time.Unix(100, 0))
+	remote := Timestamp{WallTime: time.Unix(200, 0).UnixNano(), Logical: 4, Replica: "b"}
+
+	clock.Observe(remote)
+	if next := clock.Now(); !remote.Less(next) {
+		t.Errorf("Expected local readings to follow an observed remote timestamp: %+v %+v", remote, next)
+	}
+}
+
+func TestTimestampOrdersByReplicaOnTies(t *testing.T) {
+	a := Timestamp{WallTime: 1, Logical: 1, Replica: "a"}
+	b := Timestamp{WallTime: 1, Logical: 1, Replica: "b"}
+
+	if !a.Less(b) || b.Less(a) {
+		t.Errorf("Expected replica ID to break ties")
+	}
+	if !(Timestamp{}).Less(a) || !(Timestamp{}).IsZero() {
+		t.Errorf("Expected the zero timestamp to be before every reading")
+	}
+}
@@ -0,0 +1,203 @@
+package crdt
+
+import (
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/change"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Update is a change list made on one replica, stamped with the hybrid logical clock reading it was made at
+type Update struct {
+	Timestamp Timestamp        `json:"timestamp"`
+	Changes   []compare.Change `json:"changes"`
+
+	// Sequences holds the element operations for slice fields tagged `sync:"sequence"`
+	Sequences map[string][]SequenceOp `json:"sequences,omitempty"`
+}
+
+// LWW replicates a struct of type T with every field acting as a last-writer-wins register.
+//
+// Each top-level field remembers the timestamp of the update that last wrote it, and an incoming change only
+// takes effect when its update is newer. Because timestamps are totally ordered, replicas that have
+// merged the same updates hold the same value regardless of delivery order or duplicates. All replicas
+// must start from the same initial value.
+//
+// Slice fields tagged `sync:"sequence"` are replicated element by element as a Sequence instead, so
+// concurrent inserts and removes from different replicas are all kept rather than one list winning.
+type LWW[T any] struct {
+	mu        sync.Mutex
+	value     T
+	clock     *Clock
+	stamps    map[string]Timestamp
+	sequences map[string]*Sequence
+}
+
+// NewLWW creates the replica named replica, starting at initial
+func NewLWW[T any](replica string, initial T) *LWW[T] {
+	r := &LWW[T]{
+		value:     initial,
+		clock:     NewClock(replica),
+		stamps:    make(map[string]Timestamp),
+		sequences: make(map[string]*Sequence),
+	}
+
+	value := reflect.ValueOf(initial)
+	if value.Kind() == reflect.Struct {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if _, ok := compare.SyncOption(field, "sequence"); ok && field.IsExported() && field.Type.Kind() == reflect.Slice {
+				r.sequences[field.Name] = NewSequence(sequenceValues(value.Field(i)))
+			}
+		}
+	}
+	return r
+}
+
+// Value returns the current value of the replica
+func (r *LWW[T]) Value() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value
+}
+
+// Stamp returns the timestamp of the update that last wrote field, the zero timestamp if none has
+func (r *LWW[T]) Stamp(field string) Timestamp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stamps[field]
+}
+
+// Apply applies local changes and returns the update to broadcast to the other replicas. Changes must
+// name top-level fields; nested paths, as CompareStructsDeep reports them, are rejected.
+func (r *LWW[T]) Apply(changes []compare.Change) (Update, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes, err := compare.TypedChanges(r.value, changes)
+	if err != nil {
+		return Update{}, err
+	}
+
+	// Changes to sequence fields become element operations against the local sequence
+	update := Update{Changes: make([]compare.Change, 0, len(changes))}
+	for _, c := range changes {
+		sequence, ok := r.sequences[c.Field]
+		if !ok {
+			update.Changes = append(update.Changes, c)
+			continue
+		}
+
+		var values []interface{}
+		if c.NewValue != nil {
+			values = sequenceValues(reflect.ValueOf(c.NewValue))
+		}
+		if update.Sequences == nil {
+			update.Sequences = make(map[string][]SequenceOp)
+		}
+		update.Sequences[c.Field] = sequence.Diff(values, r.clock)
+	}
+	update.Timestamp = r.clock.Now()
+
+	if err := r.merge(update); err != nil {
+		return Update{}, err
+	}
+	return update, nil
+}
+
+// Set replaces the local value with next and returns the update to broadcast
+func (r *LWW[T]) Set(next T) (Update, error) {
+	r.mu.Lock()
+	current := r.value
+	r.mu.Unlock()
+
+	changes, err := compare.CompareStructs(current, next)
+	if err != nil {
+		return Update{}, err
+	}
+	return r.Apply(changes)
+}
+
+// Merge applies an update received from another replica. Changes to fields that have since been
+// written by a newer update are ignored, and merging the same update twice has no effect.
+func (r *LWW[T]) Merge(update Update) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clock.Observe(update.Timestamp)
+	return r.merge(update)
+}
+
+// merge - applies the changes of update that win their field's register and integrates its sequence
+// operations, leaving the replica untouched when any of it fails. The caller must hold the lock.
+func (r *LWW[T]) merge(update Update) error {
+	winning := make([]compare.Change, 0, len(update.Changes))
+	for _, c := range update.Changes {
+		if _, ok := r.sequences[c.Field]; ok {
+			return fmt.Errorf("field %s is a sequence and cannot be written as a register", c.Field)
+		}
+		if strings.Contains(c.Field, ".") {
+			// Registers are whole top-level fields, a nested path and its parent would carry separate stamps
+			return fmt.Errorf("field %s is nested, registers are written as whole top-level fields", c.Field)
+		}
+		if r.stamps[c.Field].Less(update.Timestamp) {
+			winning = append(winning, c)
+		}
+	}
+
+	// Operations are integrated into copies that only replace the sequences once the update applies
+	integrated := make(map[string]*Sequence, len(update.Sequences))
+	for field, ops := range update.Sequences {
+		sequence, ok := r.sequences[field]
+		if !ok {
+			return fmt.Errorf("field %s is not a sequence", field)
+		}
+		sequence = sequence.clone()
+		integrated[field] = sequence
+		fieldType, _ := reflect.TypeOf(r.value).FieldByName(field)
+		for _, op := range ops {
+			// Keep elements typed so later diffs compare them against local values
+			if !op.Remove {
+				value, err := typedSlice(fieldType.Type, []interface{}{op.Value})
+				if err != nil {
+					return fmt.Errorf("field %s: %w", field, err)
+				}
+				op.Value = value.Index(0).Interface()
+			}
+			sequence.Integrate(op)
+		}
+
+		slice, err := typedSlice(fieldType.Type, sequence.Values())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field, err)
+		}
+		winning = append(winning, compare.Change{Field: field, ChangeType: compare.Modified, NewValue: slice.Interface()})
+	}
+	if len(winning) == 0 {
+		return nil
+	}
+
+	// Updates that went through JSON carry generic values
+	winning, err := compare.TypedChanges(r.value, winning)
+	if err != nil {
+		return err
+	}
+
+	result, err := change.ApplyChanges(r.value, winning)
+	if err != nil {
+		return fmt.Errorf("cannot merge update from %s: %w", update.Timestamp.Replica, err)
+	}
+
+	r.value = result.(T)
+	for field, sequence := range integrated {
+		r.sequences[field] = sequence
+	}
+	for _, c := range winning {
+		if _, ok := r.sequences[c.Field]; !ok {
+			r.stamps[c.Field] = update.Timestamp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package crdt
+
+import (
+	"encoding/json"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Config struct {
+	Name    string
+	Retries int
+	Hosts   []string
+}
+
+type Service struct {
+	Name  string
+	Owner *Owner
+}
+
+type Owner struct {
+	Name string
+	Team string
+}
+
+func TestLWWConvergesRegardlessOfDeliveryOrder(t *testing.T) {
+	start := time.Unix(1000, 0)
+	a := NewLWW("a", Config{Name: "svc"})
+	b := NewLWW("b", Config{Name: "svc"})
+	c := NewLWW("c", Config{Name: "svc"})
+	a.clock, _ = fixedClock("a", start)
+	b.clock, _ = fixedClock("b", start)
+	c.clock, _ = fixedClock("c", start)
+
+	// Concurrent writes to the same field at the same wall time, plus a write to another field
+	fromA, _ := a.Set(Config{Name: "svc", Retries: 3})
+	fromB, _ := b.Set(Config{Name: "svc", Retries: 5, Hosts: []string{"h1"}})
+	fromC, _ := c.Set(Config{Name: "renamed"})
+
+	a.Merge(fromB)
+	a.Merge(fromC)
+
+	b.Merge(fromC)
+	b.Merge(fromA)
+
+	c.Merge(fromA)
+	c.Merge(fromB)
+	c.Merge(fromA) // duplicate delivery
+
+	va, vb, vc := a.Value(), b.Value(), c.Value()
+	if !reflect.DeepEqual(va, vb) || !reflect.DeepEqual(vb, vc) {
+		t.Fatalf("Replicas did not converge: %+v %+v %+v", va, vb, vc)
+	}
+
+	// Replica b wins the tie on Retries
+	if va.Retries != 5 || va.Name != "renamed" || len(va.Hosts) != 1 {
+		t.Errorf("Unexpected converged value %+v", va)
+	}
+}
+
+func TestLWWIgnoresOlderWrites(t *testing.T) {
+	a := NewLWW("a", Config{})
+	b := NewLWW("b", Config{})
+	var advanceA func(time.Duration)
+	a.clock, advanceA = fixedClock("a", time.Unix(1000, 0))
+	b.clock, _ = fixedClock("b", time.Unix(1000, 0))
+
+	old, _ := b.Set(Config{Name: "old"})
+	advanceA(time.Second)
+	newer, _ := a.Set(Config{Name: "new"})
+
+	a.Merge(old)
+	if a.Value().Name != "new" {
+		t.Errorf("Expected the newer local write to win, got %+v", a.Value())
+	}
+
+	b.Merge(newer)
+	if b.Value().Name != "new" {
+		t.Errorf("Expected the newer remote write to win, got %+v", b.Value())
+	}
+	if b.Stamp("Name") != newer.Timestamp {
+		t.Errorf("Expected the field stamp to follow the winning update")
+	}
+}
+
+func TestLWWMergesUpdatesAfterJSONRoundTrip(t *testing.T) {
+	a := NewLWW("a", Config{})
+	b := NewLWW("b", Config{})
+
+	update, err := a.Apply([]compare.Change{
+		{Field: "Retries", ChangeType: compare.Modified, NewValue: 7},
+		{Field: "Hosts", ChangeType: compare.Added, NewValue: []string{"h1", "h2"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var received Update
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if err := b.Merge(received); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !reflect.DeepEqual(a.Value(), b.Value()) {
+		t.Errorf("Expected replicas to match, got %+v and %+v", a.Value(), b.Value())
+	}
+}
+
+func TestLWWMergeFailsOnUnknownField(t *testing.T) {
+	r := NewLWW("a", Config{})
+	err := r.Merge(Update{Timestamp: Timestamp{WallTime: 1, Replica: "b"}, Changes: []compare.Change{
+		{Field: "Missing", ChangeType: compare.Modified, NewValue: 1},
+	}})
+	if err == nil {
+		t.Error("Expected error when merging a change to an unknown field")
+	}
+}
+
+func TestLWWRejectsNestedPaths(t *testing.T) {
+	a := NewLWW("a", Service{Name: "svc"})
+	b := NewLWW("b", Service{Name: "svc"})
+
+	parent, err := a.Apply([]compare.Change{{Field: "Owner", ChangeType: compare.Added, NewValue: &Owner{Name: "ann", Team: "core"}}})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := b.Apply([]compare.Change{{Field: "Owner.Name", ChangeType: compare.Modified, NewValue: "bob"}}); err == nil {
+		t.Error("Expected error when writing a nested path")
+	}
+	child := Update{Timestamp: Timestamp{WallTime: parent.Timestamp.WallTime + 1, Replica: "b"}, Changes: []compare.Change{
+		{Field: "Owner.Name", ChangeType: compare.Modified, NewValue: "bob"},
+	}}
+
+	// Whatever order the parent and child writes arrive in, both replicas end up with the parent write
+	if err := a.Merge(child); err == nil {
+		t.Error("Expected error when merging a nested path")
+	}
+	if err := b.Merge(parent); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := b.Merge(child); err == nil {
+		t.Error("Expected error when merging a nested path")
+	}
+
+	va, vb := a.Value(), b.Value()
+	if !reflect.DeepEqual(va, vb) || va.Owner.Name != "ann" {
+		t.Errorf("Replicas did not converge on the parent write: %+v %+v", va.Owner, vb.Owner)
+	}
+}
@@ -0,0 +1,226 @@
+package crdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// SequenceOp is a single element insert or remove on a Sequence
+type SequenceOp struct {
+	// Remove is false for inserts
+	Remove bool `json:"remove,omitempty"`
+	// ID identifies the inserted or removed element
+	ID Timestamp `json:"id"`
+	// After is the element the insert follows, the zero timestamp for the head of the sequence
+	After Timestamp `json:"after,omitempty"`
+	// Value is the inserted element
+	Value interface{} `json:"value,omitempty"`
+}
+
+// element is a node of the sequence, kept as a tombstone once removed
+type element struct {
+	id      Timestamp
+	value   interface{}
+	removed bool
+}
+
+// Sequence is a replicated growable array (RGA). Every element is identified by the hybrid logical
+// clock reading it was inserted at and anchored after the element it followed, so concurrent inserts
+// and removes from different replicas interleave deterministically and all replicas converge.
+type Sequence struct {
+	elements []*element
+	index    map[Timestamp]*element
+
+	// Operations whose anchor has not arrived yet, and removes that arrived before their insert
+	pending []SequenceOp
+	removed map[Timestamp]bool
+}
+
+// NewSequence creates a sequence holding values. Replicas seeded with the same values get the same
+// element IDs, so they can exchange operations from the start.
+func NewSequence(values []interface{}) *Sequence {
+	s := &Sequence{index: make(map[Timestamp]*element), removed: make(map[Timestamp]bool)}
+
+	var after Timestamp
+	for i, value := range values {
+		id := Timestamp{Logical: uint32(i + 1)}
+		s.Integrate(SequenceOp{ID: id, After: after, Value: value})
+		after = id
+	}
+	return s
+}
+
+// Values returns the visible elements in order
+func (s *Sequence) Values() []interface{} {
+	values := make([]interface{}, 0, len(s.elements))
+	for _, e := range s.elements {
+		if !e.removed {
+			values = append(values, e.value)
+		}
+	}
+	return values
+}
+
+// Integrate applies an operation from any replica. Operations are idempotent, and an insert anchored
+// to an element that has not arrived yet is held back until it does.
+func (s *Sequence) Integrate(op SequenceOp) {
+	if !s.integrate(op) {
+		s.pending = append(s.pending, op)
+		return
+	}
+
+	// Retry held back operations until none of them can make progress
+	for progress := true; progress; {
+		progress = false
+		for i := 0; i < len(s.pending); i++ {
+			if s.integrate(s.pending[i]) {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				i--
+				progress = true
+			}
+		}
+	}
+}
+
+// Diff returns the operations that turn the visible elements into values, stamping inserts with clock
+func (s *Sequence) Diff(values []interface{}, clock *Clock) []SequenceOp {
+	visible := make([]*element, 0, len(s.elements))
+	for _, e := range s.elements {
+		if !e.removed {
+			visible = append(visible, e)
+		}
+	}
+
+	// Longest common subsequence of the current and desired values
+	lcs := make([][]int, len(visible)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(values)+1)
+	}
+	for i := len(visible) - 1; i >= 0; i-- {
+		for j := len(values) - 1; j >= 0; j-- {
+			if reflect.DeepEqual(visible[i].value, values[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]SequenceOp, 0)
+	var after Timestamp
+	i, j := 0, 0
+	for i < len(visible) || j < len(values) {
+		switch {
+		case i < len(visible) && j < len(values) && reflect.DeepEqual(visible[i].value, values[j]):
+			after = visible[i].id
+			i++
+			j++
+		case j < len(values) && (i == len(visible) || lcs[i][j+1] >= lcs[i+1][j]):
+			id := clock.Now()
+			ops = append(ops, SequenceOp{ID: id, After: after, Value: values[j]})
+			after = id
+			j++
+		default:
+			ops = append(ops, SequenceOp{Remove: true, ID: visible[i].id})
+			i++
+		}
+	}
+	return ops
+}
+
+// integrate - applies op if its dependencies are present, reporting whether it could
+func (s *Sequence) integrate(op SequenceOp) bool {
+	if op.Remove {
+		if e, ok := s.index[op.ID]; ok {
+			e.removed = true
+		} else {
+			s.removed[op.ID] = true
+		}
+		return true
+	}
+
+	if _, ok := s.index[op.ID]; ok {
+		return true
+	}
+
+	position := 0
+	if !op.After.IsZero() {
+		anchor, ok := s.index[op.After]
+		if !ok {
+			return false
+		}
+		position = s.position(anchor) + 1
+	}
+
+	// Skip over newer inserts at the same anchor and their descendants, which all carry greater IDs
+	for position < len(s.elements) && op.ID.Less(s.elements[position].id) {
+		position++
+	}
+
+	e := &element{id: op.ID, value: op.Value, removed: s.removed[op.ID]}
+	delete(s.removed, op.ID)
+	s.elements = append(s.elements, nil)
+	copy(s.elements[position+1:], s.elements[position:])
+	s.elements[position] = e
+	s.index[op.ID] = e
+	return true
+}
+
+// clone - returns a copy of the sequence that shares no state with it
+func (s *Sequence) clone() *Sequence {
+	c := &Sequence{
+		elements: make([]*element, len(s.elements)),
+		index:    make(map[Timestamp]*element, len(s.index)),
+		pending:  append([]SequenceOp(nil), s.pending...),
+		removed:  make(map[Timestamp]bool, len(s.removed)),
+	}
+	for i, e := range s.elements {
+		copied := *e
+		c.elements[i] = &copied
+		c.index[copied.id] = &copied
+	}
+	for id := range s.removed {
+		c.removed[id] = true
+	}
+	return c
+}
+
+// position - returns the index of an element
+func (s *Sequence) position(e *element) int {
+	for i, candidate := range s.elements {
+		if candidate == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// sequenceValues - returns the elements of a slice value as interface values
+func sequenceValues(slice reflect.Value) []interface{} {
+	values := make([]interface{}, slice.Len())
+	for i := range values {
+		values[i] = slice.Index(i).Interface()
+	}
+	return values
+}
+
+// typedSlice - builds a slice of sliceType from sequence values, decoding values that went through JSON
+func typedSlice(sliceType reflect.Type, values []interface{}) (reflect.Value, error) {
+	slice := reflect.MakeSlice(sliceType, len(values), len(values))
+	for i, value := range values {
+		if value != nil && reflect.TypeOf(value).AssignableTo(sliceType.Elem()) {
+			slice.Index(i).Set(reflect.ValueOf(value))
+			continue
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if err := json.Unmarshal(data, slice.Index(i).Addr().Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot decode sequence element: %w", err)
+		}
+	}
+	return slice, nil
+}
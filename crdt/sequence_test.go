@@ -0,0 +1,151 @@
+package crdt
+
+import (
+	"encoding/json"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Playlist struct {
+	Name  string
+	Songs []string `sync:"sequence"`
+}
+
+func TestSequenceConcurrentInsertsConverge(t *testing.T) {
+	start := time.Unix(1000, 0)
+	a := NewLWW("a", Playlist{Songs: []string{"intro", "outro"}})
+	b := NewLWW("b", Playlist{Songs: []string{"intro", "outro"}})
+	a.clock, _ = fixedClock("a", start)
+	b.clock, _ = fixedClock("b", start)
+
+	// Both replicas insert between the same two songs, and b also removes the outro
+	fromA, err := a.Set(Playlist{Songs: []string{"intro", "a1", "a2", "outro"}})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	fromB, err := b.Set(Playlist{Songs: []string{"intro", "b1"}})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := a.Merge(fromB); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := b.Merge(fromA); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	b.Merge(fromA) // duplicate delivery
+
+	va, vb := a.Value(), b.Value()
+	if !reflect.DeepEqual(va, vb) {
+		t.Fatalf("Replicas did not converge: %v and %v", va.Songs, vb.Songs)
+	}
+
+	// Every insert is kept, the inserts of each replica stay together, and the removal wins
+	expected := []string{"intro", "b1", "a1", "a2"}
+	if !reflect.DeepEqual(va.Songs, expected) {
+		t.Errorf("Expected %v, got %v", expected, va.Songs)
+	}
+}
+
+func TestSequenceLaterEditsBuildOnMergedElements(t *testing.T) {
+	a := NewLWW("a", Playlist{})
+	b := NewLWW("b", Playlist{})
+
+	first, _ := a.Set(Playlist{Name: "mix", Songs: []string{"x", "y"}})
+	b.Merge(first)
+
+	second, err := b.Set(Playlist{Name: "mix", Songs: []string{"x", "z", "y"}})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if len(second.Sequences["Songs"]) != 1 || len(second.Changes) != 0 {
+		t.Errorf("Expected a single insert, got %+v", second)
+	}
+
+	a.Merge(second)
+	if got := a.Value(); got.Name != "mix" || !reflect.DeepEqual(got.Songs, []string{"x", "z", "y"}) {
+		t.Errorf("Unexpected value %+v", got)
+	}
+}
+
+func TestSequenceOperationsAfterJSONRoundTrip(t *testing.T) {
+	a := NewLWW("a", Playlist{})
+	b := NewLWW("b", Playlist{})
+
+	update, _ := a.Set(Playlist{Songs: []string{"x", "y"}})
+	data, err := json.Marshal(update)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var received Update
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if err := b.Merge(received); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	// Elements received as JSON must still match local values when b diffs against them
+	next, _ := b.Set(Playlist{Songs: []string{"x"}})
+	ops := next.Sequences["Songs"]
+	if len(ops) != 1 || !ops[0].Remove {
+		t.Errorf("Expected a single remove, got %+v", ops)
+	}
+}
+
+func TestSequenceHoldsBackOperationsWithMissingAnchors(t *testing.T) {
+	clock := NewClock("a")
+	source := NewSequence(nil)
+	ops := source.Diff([]interface{}{"x", "y", "z"}, clock)
+	remove := SequenceOp{Remove: true, ID: ops[1].ID}
+
+	s := NewSequence(nil)
+	s.Integrate(remove)
+	s.Integrate(ops[2])
+	if len(s.Values()) != 0 {
+		t.Errorf("Expected nothing visible before the anchors arrive, got %v", s.Values())
+	}
+
+	s.Integrate(ops[1])
+	s.Integrate(ops[0])
+	if got := s.Values(); !reflect.DeepEqual(got, []interface{}{"x", "z"}) {
+		t.Errorf("Expected [x z], got %v", got)
+	}
+}
+
+func TestSequenceFieldRejectsRegisterWrites(t *testing.T) {
+	r := NewLWW("a", Playlist{})
+	err := r.Merge(Update{Timestamp: Timestamp{WallTime: 1, Replica: "b"}, Changes: []compare.Change{
+		{Field: "Songs", ChangeType: compare.Modified, NewValue: []string{"x"}},
+	}})
+	if err == nil {
+		t.Error("Expected error when writing a sequence field as a register")
+	}
+}
+
+func TestSequenceUnchangedWhenMergeFails(t *testing.T) {
+	a := NewLWW("a", Playlist{Songs: []string{"x"}})
+	b := NewLWW("b", Playlist{Songs: []string{"x"}})
+
+	update, _ := a.Set(Playlist{Songs: []string{"x", "y"}})
+	update.Changes = append(update.Changes, compare.Change{Field: "Missing", ChangeType: compare.Modified, NewValue: 1})
+
+	if err := b.Merge(update); err == nil {
+		t.Fatal("Expected merge of a change to an unknown field to fail")
+	}
+	if got := b.sequences["Songs"].Values(); !reflect.DeepEqual(got, []interface{}{"x"}) {
+		t.Errorf("Expected sequence to be rolled back, got %v", got)
+	}
+
+	// The same operations apply once the update is valid
+	update.Changes = nil
+	if err := b.Merge(update); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if got := b.Value().Songs; !reflect.DeepEqual(got, []string{"x", "y"}) {
+		t.Errorf("Expected [x y], got %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package crdt
+
+// VectorClock counts the events seen from each replica
+type VectorClock map[string]uint64
+
+// Ordering is the causal relation between two vector clocks
+type Ordering int
+
+const (
+	// Equal clocks have seen exactly the same events
+	Equal Ordering = iota
+	// Before means every event seen by the first clock was also seen by the second
+	Before
+	// After means every event seen by the second clock was also seen by the first
+	After
+	// Concurrent clocks have each seen events the other has not
+	Concurrent
+)
+
+// String returns the name of the ordering
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	default:
+		return "concurrent"
+	}
+}
+
+// Compare returns how v relates causally to other
+func (v VectorClock) Compare(other VectorClock) Ordering {
+	less, greater := false, false
+
+	for replica, count := range v {
+		if count > other[replica] {
+			greater = true
+		} else if count < other[replica] {
+			less = true
+		}
+	}
+	for replica, count := range other {
+		if _, ok := v[replica]; !ok && count > 0 {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	default:
+		return Equal
+	}
+}
+
+// Copy returns an independent copy of v
+func (v VectorClock) Copy() VectorClock {
+	copied := make(VectorClock, len(v))
+	for replica, count := range v {
+		copied[replica] = count
+	}
+	return copied
+}
+
+// Tick returns a copy of v with the event count of replica incremented
+func (v VectorClock) Tick(replica string) VectorClock {
+	ticked := v.Copy()
+	ticked[replica]++
+	return ticked
+}
+
+// Merge returns the element-wise maximum of v and other
+func (v VectorClock) Merge(other VectorClock) VectorClock {
+	merged := v.Copy()
+	for replica, count := range other {
+		if count > merged[replica] {
+			merged[replica] = count
+		}
+	}
+	return merged
+}
@@ -0,0 +1,40 @@
+package crdt
+
+import "testing"
+
+func TestVectorClockCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     VectorClock
+		expected Ordering
+	}{
+		{"equal", VectorClock{"a": 1, "b": 2}, VectorClock{"a": 1, "b": 2}, Equal},
+		{"missing entries count as zero", VectorClock{"a": 1, "b": 0}, VectorClock{"a": 1}, Equal},
+		{"before", VectorClock{"a": 1}, VectorClock{"a": 1, "b": 1}, Before},
+		{"after", VectorClock{"a": 2, "b": 1}, VectorClock{"a": 1, "b": 1}, After},
+		{"concurrent", VectorClock{"a": 2}, VectorClock{"a": 1, "b": 1}, Concurrent},
+	}
+
+	for _, test := range tests {
+		if got := test.a.Compare(test.b); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestVectorClockTickAndMergeDoNotMutate(t *testing.T) {
+	original := VectorClock{"a": 1}
+
+	ticked := original.Tick("b")
+	merged := original.Merge(VectorClock{"a": 3, "c": 1})
+
+	if original["a"] != 1 || len(original) != 1 {
+		t.Errorf("Expected original clock to be unchanged, got %v", original)
+	}
+	if ticked["a"] != 1 || ticked["b"] != 1 {
+		t.Errorf("Unexpected ticked clock %v", ticked)
+	}
+	if merged["a"] != 3 || merged["c"] != 1 {
+		t.Errorf("Unexpected merged clock %v", merged)
+	}
+}
@@ -2,3 +2,4 @@ module github.com/rschoonheim/go-struct-sync-v1
 
 go 1.23
 
+require google.golang.org/protobuf v1.36.11
@@ -0,0 +1,237 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/change"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrNothingToUndo is returned by Undo when the undo stack is empty
+	ErrNothingToUndo = errors.New("history: nothing to undo")
+	// ErrNothingToRedo is returned by Redo when the redo stack is empty
+	ErrNothingToRedo = errors.New("history: nothing to redo")
+	// ErrTransactionOpen is returned when an operation is not allowed while a transaction is open
+	ErrTransactionOpen = errors.New("history: transaction in progress")
+	// ErrNoTransaction is returned by Commit and Rollback when no transaction is open
+	ErrNoTransaction = errors.New("history: no transaction in progress")
+)
+
+// Entry is a single undoable step: either one applied change list or a committed transaction
+type Entry struct {
+	Name    string
+	Changes []compare.Change
+}
+
+// History tracks the change lists applied to a struct value of type T and supports undo and redo.
+// Changes can be grouped into named transactions, which undo and redo as a single step.
+type History[T any] struct {
+	mu       sync.Mutex
+	current  T
+	maxDepth int
+	undo     []Entry
+	redo     []Entry
+
+	// Open transaction, if any, with the value it started from
+	tx      *Entry
+	txStart T
+}
+
+// New creates a history starting at initial. maxDepth bounds the number of undoable steps,
+// dropping the oldest ones first; 0 means unbounded.
+func New[T any](initial T, maxDepth int) *History[T] {
+	return &History[T]{current: initial, maxDepth: maxDepth}
+}
+
+// Current returns the current value
+func (h *History[T]) Current() T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.current
+}
+
+// Apply applies changes to the current value and records them as an undoable step,
+// or adds them to the open transaction. Applying new changes clears the redo stack.
+func (h *History[T]) Apply(changes []compare.Change) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.apply("", changes)
+}
+
+// ApplyNamed applies changes like Apply, naming the undoable step
+func (h *History[T]) ApplyNamed(name string, changes []compare.Change) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.apply(name, changes)
+}
+
+// Set replaces the current value with next, recording the difference as an undoable step
+func (h *History[T]) Set(next T) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changes, err := compare.CompareStructs(h.current, next)
+	if err != nil {
+		return err
+	}
+	return h.apply("", changes)
+}
+
+// Undo reverts the most recent step
+func (h *History[T]) Undo() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx != nil {
+		return ErrTransactionOpen
+	}
+	if len(h.undo) == 0 {
+		return ErrNothingToUndo
+	}
+
+	// Later changes can build on earlier ones, like a field set inside a struct replaced before it,
+	// so they are reverted last to first
+	entry := h.undo[len(h.undo)-1]
+	reverted := compare.RevertChanges(entry.Changes)
+	slices.Reverse(reverted)
+	if err := h.set(reverted); err != nil {
+		return fmt.Errorf("cannot undo %q: %w", entry.Name, err)
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, entry)
+	return nil
+}
+
+// Redo reapplies the most recently undone step
+func (h *History[T]) Redo() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx != nil {
+		return ErrTransactionOpen
+	}
+	if len(h.redo) == 0 {
+		return ErrNothingToRedo
+	}
+
+	entry := h.redo[len(h.redo)-1]
+	if err := h.set(entry.Changes); err != nil {
+		return fmt.Errorf("cannot redo %q: %w", entry.Name, err)
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.push(entry)
+	return nil
+}
+
+// Begin opens a named transaction. Changes applied until Commit are undone and redone as one step.
+func (h *History[T]) Begin(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx != nil {
+		return ErrTransactionOpen
+	}
+	h.tx = &Entry{Name: name}
+	h.txStart = h.current
+	return nil
+}
+
+// Commit closes the open transaction and records it as a single undoable step.
+// A transaction whose changes cancel out leaves no step behind.
+func (h *History[T]) Commit() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx == nil {
+		return ErrNoTransaction
+	}
+	entry := *h.tx
+	h.tx = nil
+
+	if len(entry.Changes) > 0 {
+		h.push(entry)
+		h.redo = nil
+	}
+	return nil
+}
+
+// Rollback closes the open transaction and restores the value it started from
+func (h *History[T]) Rollback() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx == nil {
+		return ErrNoTransaction
+	}
+	h.current = h.txStart
+	h.tx = nil
+	return nil
+}
+
+// CanUndo reports whether there is a step to undo
+func (h *History[T]) CanUndo() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.tx == nil && len(h.undo) > 0
+}
+
+// CanRedo reports whether there is a step to redo
+func (h *History[T]) CanRedo() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.tx == nil && len(h.redo) > 0
+}
+
+// UndoStack returns the undoable steps, oldest first
+func (h *History[T]) UndoStack() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Entry(nil), h.undo...)
+}
+
+// RedoStack returns the redoable steps, the next one to redo last
+func (h *History[T]) RedoStack() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Entry(nil), h.redo...)
+}
+
+// apply - applies and records changes, the caller must hold the lock
+func (h *History[T]) apply(name string, changes []compare.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := h.set(changes); err != nil {
+		return err
+	}
+
+	if h.tx != nil {
+		h.tx.Changes = compare.Squash(h.tx.Changes, changes)
+		return nil
+	}
+
+	h.push(Entry{Name: name, Changes: changes})
+	h.redo = nil
+	return nil
+}
+
+// set - applies changes to the current value, the caller must hold the lock
+func (h *History[T]) set(changes []compare.Change) error {
+	result, err := change.ApplyChanges(h.current, changes)
+	if err != nil {
+		return err
+	}
+	h.current = result.(T)
+	return nil
+}
+
+// push - adds an entry to the undo stack, dropping the oldest beyond maxDepth
+func (h *History[T]) push(entry Entry) {
+	h.undo = append(h.undo, entry)
+	if h.maxDepth > 0 && len(h.undo) > h.maxDepth {
+		h.undo = append([]Entry(nil), h.undo[len(h.undo)-h.maxDepth:]...)
+	}
+}
@@ -0,0 +1,186 @@
+package history
+
+import (
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"testing"
+)
+
+type Shape struct {
+	Name  string
+	X     int
+	Y     int
+	Color string
+}
+
+func TestUndoRedoRestoresValues(t *testing.T) {
+	h := New(Shape{Name: "box"}, 0)
+
+	if err := h.Set(Shape{Name: "box", X: 10}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := h.Apply([]compare.Change{{Field: "Color", ChangeType: compare.Added, NewValue: "red"}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if current := h.Current(); current.Color != "" || current.X != 10 {
+		t.Errorf("Expected Color to be undone, got %+v", current)
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if current := h.Current(); current != (Shape{Name: "box"}) {
+		t.Errorf("Expected initial value, got %+v", current)
+	}
+	if err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo, got %v", err)
+	}
+
+	if err := h.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if err := h.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if current := h.Current(); current.X != 10 || current.Color != "red" {
+		t.Errorf("Expected both steps to be redone, got %+v", current)
+	}
+	if err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Expected ErrNothingToRedo, got %v", err)
+	}
+}
+
+func TestApplyClearsRedoStack(t *testing.T) {
+	h := New(Shape{}, 0)
+	h.Set(Shape{X: 1})
+	h.Undo()
+
+	if !h.CanRedo() {
+		t.Fatalf("Expected a step to redo")
+	}
+	h.Set(Shape{Y: 1})
+	if h.CanRedo() {
+		t.Errorf("Expected new changes to clear the redo stack")
+	}
+}
+
+func TestMaxDepthDropsOldestSteps(t *testing.T) {
+	h := New(Shape{}, 2)
+	for x := 1; x <= 4; x++ {
+		h.Set(Shape{X: x})
+	}
+
+	if len(h.UndoStack()) != 2 {
+		t.Fatalf("Expected 2 undoable steps, got %d", len(h.UndoStack()))
+	}
+	h.Undo()
+	h.Undo()
+	if current := h.Current(); current.X != 2 {
+		t.Errorf("Expected to stop at X=2, got %+v", current)
+	}
+	if h.CanUndo() {
+		t.Errorf("Expected the oldest steps to be dropped")
+	}
+}
+
+func TestTransactionUndoesAsOneStep(t *testing.T) {
+	h := New(Shape{Name: "box"}, 0)
+
+	h.Begin("move")
+	h.Set(Shape{Name: "box", X: 5})
+	h.Set(Shape{Name: "box", X: 5, Y: 7})
+	if err := h.Undo(); !errors.Is(err, ErrTransactionOpen) {
+		t.Errorf("Expected ErrTransactionOpen, got %v", err)
+	}
+	if err := h.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	stack := h.UndoStack()
+	if len(stack) != 1 || stack[0].Name != "move" || len(stack[0].Changes) != 2 {
+		t.Fatalf("Expected a single named step, got %+v", stack)
+	}
+
+	h.Undo()
+	if current := h.Current(); current != (Shape{Name: "box"}) {
+		t.Errorf("Expected the whole transaction to be undone, got %+v", current)
+	}
+	h.Redo()
+	if current := h.Current(); current.X != 5 || current.Y != 7 {
+		t.Errorf("Expected the whole transaction to be redone, got %+v", current)
+	}
+}
+
+type Person struct {
+	Name    string
+	Manager *Person
+}
+
+func TestUndoRevertsParentAndChildChanges(t *testing.T) {
+	h := New(Person{Name: "John", Manager: &Person{Name: "X"}}, 0)
+
+	h.Begin("promote")
+	if err := h.Apply([]compare.Change{{Field: "Manager", ChangeType: compare.Modified, OldValue: &Person{Name: "X"}, NewValue: &Person{Name: "Y"}}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := h.Apply([]compare.Change{{Field: "Manager.Name", ChangeType: compare.Modified, OldValue: "Y", NewValue: "Z"}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	h.Commit()
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if manager := h.Current().Manager; manager == nil || manager.Name != "X" {
+		t.Errorf("Expected manager X after undo, got %+v", manager)
+	}
+
+	if err := h.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if manager := h.Current().Manager; manager == nil || manager.Name != "Z" {
+		t.Errorf("Expected manager Z after redo, got %+v", manager)
+	}
+}
+
+func TestRollbackRestoresTransactionStart(t *testing.T) {
+	h := New(Shape{Name: "box"}, 0)
+
+	h.Begin("edit")
+	h.Set(Shape{Name: "circle", Color: "blue"})
+	if err := h.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if current := h.Current(); current != (Shape{Name: "box"}) {
+		t.Errorf("Expected rollback to restore the start value, got %+v", current)
+	}
+	if h.CanUndo() {
+		t.Errorf("Expected rolled back transaction to leave no step")
+	}
+	if err := h.Commit(); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Expected ErrNoTransaction, got %v", err)
+	}
+}
+
+func TestApplyNamedAndFailedApply(t *testing.T) {
+	h := New(Shape{}, 0)
+
+	if err := h.ApplyNamed("rename", []compare.Change{{Field: "Name", ChangeType: compare.Added, NewValue: "box"}}); err != nil {
+		t.Fatalf("ApplyNamed failed: %v", err)
+	}
+	if stack := h.UndoStack(); len(stack) != 1 || stack[0].Name != "rename" {
+		t.Errorf("Expected named step, got %+v", stack)
+	}
+
+	if err := h.Apply([]compare.Change{{Field: "Missing", ChangeType: compare.Modified, NewValue: 1}}); err == nil {
+		t.Error("Expected error when applying changes to a missing field")
+	}
+	if len(h.UndoStack()) != 1 {
+		t.Errorf("Expected a failed apply not to be recorded")
+	}
+}
@@ -0,0 +1,251 @@
+// Package mongo converts change lists into MongoDB-style update documents
+package mongo
+
+import (
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// UpdateDocument converts changes made to target, the struct after the changes, into an update document
+// using the $set, $unset, $push and $pull operators.
+//
+// Field paths may be nested ("Manager.Name") and may address slice elements or map entries
+// ("Items.2"). Each struct field is named by its bson tag, then its json tag, and otherwise by its
+// lowercased Go name. Deleted fields are unset. A slice that only gained elements at its end is
+// extended with $push and $each, and a slice that only lost elements is shrunk with $pull and $in; any
+// other slice change replaces the whole array with $set. Unsetting an element would leave a null in the
+// array, so a deleted element replaces the whole array as it is in target.
+func UpdateDocument(target interface{}, changes []compare.Change) (map[string]interface{}, error) {
+	rootType := reflect.TypeOf(target)
+	for rootType != nil && rootType.Kind() == reflect.Ptr {
+		rootType = rootType.Elem()
+	}
+	if rootType == nil || rootType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("target must be a struct, got %T", target)
+	}
+
+	document := make(map[string]interface{})
+	operator := func(name string) map[string]interface{} {
+		fields, ok := document[name].(map[string]interface{})
+		if !ok {
+			fields = make(map[string]interface{})
+			document[name] = fields
+		}
+		return fields
+	}
+
+	// Arrays set as a whole, other operators on them or their elements would conflict
+	replaced := make([]string, 0)
+
+	for _, change := range changes {
+		path, fieldType, err := documentPath(rootType, change.Field)
+		if err != nil {
+			return nil, err
+		}
+
+		if change.ChangeType == compare.Deleted {
+			if i := strings.LastIndex(change.Field, "."); i >= 0 {
+				arrayPath, arrayType, err := documentPath(rootType, change.Field[:i])
+				if err != nil {
+					return nil, err
+				}
+				if arrayType.Kind() == reflect.Slice || arrayType.Kind() == reflect.Array {
+					array, err := valueAt(target, change.Field[:i])
+					if err != nil {
+						return nil, err
+					}
+					operator("$set")[arrayPath] = array
+					replaced = append(replaced, arrayPath)
+					continue
+				}
+			}
+			operator("$unset")[path] = ""
+			continue
+		}
+
+		if fieldType.Kind() == reflect.Slice && change.ChangeType == compare.Modified {
+			if appended, ok := appendedElements(change.OldValue, change.NewValue); ok {
+				operator("$push")[path] = map[string]interface{}{"$each": appended}
+				continue
+			}
+			if removed, ok := removedElements(change.OldValue, change.NewValue); ok {
+				operator("$pull")[path] = map[string]interface{}{"$in": removed}
+				continue
+			}
+		}
+
+		operator("$set")[path] = change.NewValue
+	}
+
+	for _, arrayPath := range replaced {
+		for name, fields := range document {
+			for key := range fields.(map[string]interface{}) {
+				if strings.HasPrefix(key, arrayPath+".") || (key == arrayPath && name != "$set") {
+					delete(fields.(map[string]interface{}), key)
+				}
+			}
+			if len(fields.(map[string]interface{})) == 0 {
+				delete(document, name)
+			}
+		}
+	}
+	return document, nil
+}
+
+// FieldName - returns the document key of a struct field, and false when the field is skipped
+func FieldName(field reflect.StructField) (string, bool) {
+	for _, key := range []string{"bson", "json"} {
+		tag, ok := field.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if tag == "-" {
+			return "", false
+		}
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name, true
+		}
+	}
+	return strings.ToLower(field.Name), true
+}
+
+// documentPath - translates a dotted Go field path into a dotted document path, returning the type
+// of the addressed value
+func documentPath(t reflect.Type, fieldPath string) (string, reflect.Type, error) {
+	segments := strings.Split(fieldPath, ".")
+	path := make([]string, 0, len(segments))
+
+	for _, segment := range segments {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := t.FieldByName(segment)
+			if !ok || !field.IsExported() {
+				return "", nil, fmt.Errorf("field %s not found in %s", fieldPath, t)
+			}
+			name, ok := FieldName(field)
+			if !ok {
+				return "", nil, fmt.Errorf("field %s is not stored in the document", fieldPath)
+			}
+			path = append(path, name)
+			t = field.Type
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(segment); err != nil {
+				return "", nil, fmt.Errorf("invalid index %s in %s", segment, fieldPath)
+			}
+			path = append(path, segment)
+			t = t.Elem()
+		case reflect.Map:
+			path = append(path, segment)
+			t = t.Elem()
+		default:
+			return "", nil, fmt.Errorf("cannot descend into %s at %s", t, fieldPath)
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.Join(path, "."), t, nil
+}
+
+// valueAt - returns the value at a dotted Go field path in target
+func valueAt(target interface{}, fieldPath string) (interface{}, error) {
+	v := reflect.ValueOf(target)
+	for _, segment := range strings.Split(fieldPath, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, fmt.Errorf("cannot read %s from target, it passes through nil", fieldPath)
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			v = v.FieldByName(segment)
+		case reflect.Slice, reflect.Array:
+			i, _ := strconv.Atoi(segment)
+			if i < 0 || i >= v.Len() {
+				return nil, fmt.Errorf("cannot read %s from target, index %d is out of range", fieldPath, i)
+			}
+			v = v.Index(i)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("cannot read %s from target, map keys are not strings", fieldPath)
+			}
+			v = v.MapIndex(reflect.ValueOf(segment).Convert(v.Type().Key()))
+		}
+		if !v.IsValid() {
+			return nil, fmt.Errorf("cannot read %s from target", fieldPath)
+		}
+	}
+	return v.Interface(), nil
+}
+
+// appendedElements - returns the elements added to the end of old, and false if the slice changed otherwise
+func appendedElements(old, new interface{}) ([]interface{}, bool) {
+	oldSlice, newSlice, ok := slices(old, new)
+	if !ok || oldSlice.Len() == 0 || newSlice.Len() <= oldSlice.Len() {
+		return nil, false
+	}
+
+	for i := 0; i < oldSlice.Len(); i++ {
+		if !reflect.DeepEqual(oldSlice.Index(i).Interface(), newSlice.Index(i).Interface()) {
+			return nil, false
+		}
+	}
+
+	appended := make([]interface{}, 0, newSlice.Len()-oldSlice.Len())
+	for i := oldSlice.Len(); i < newSlice.Len(); i++ {
+		appended = append(appended, newSlice.Index(i).Interface())
+	}
+	return appended, true
+}
+
+// removedElements - returns the elements removed from old, and false if the slice changed otherwise.
+// $pull removes every matching element, so a removed value that is still present in new is not
+// expressible and reports false.
+func removedElements(old, new interface{}) ([]interface{}, bool) {
+	oldSlice, newSlice, ok := slices(old, new)
+	if !ok || newSlice.Len() == 0 || newSlice.Len() >= oldSlice.Len() {
+		return nil, false
+	}
+
+	removed := make([]interface{}, 0, oldSlice.Len()-newSlice.Len())
+	j := 0
+	for i := 0; i < oldSlice.Len(); i++ {
+		element := oldSlice.Index(i).Interface()
+		if j < newSlice.Len() && reflect.DeepEqual(element, newSlice.Index(j).Interface()) {
+			j++
+			continue
+		}
+		removed = append(removed, element)
+	}
+	if j != newSlice.Len() {
+		return nil, false
+	}
+
+	for i := 0; i < newSlice.Len(); i++ {
+		for _, element := range removed {
+			if reflect.DeepEqual(element, newSlice.Index(i).Interface()) {
+				return nil, false
+			}
+		}
+	}
+	return removed, true
+}
+
+// slices - returns old and new as slice values
+func slices(old, new interface{}) (reflect.Value, reflect.Value, bool) {
+	oldSlice, newSlice := reflect.ValueOf(old), reflect.ValueOf(new)
+	if oldSlice.Kind() != reflect.Slice || newSlice.Kind() != reflect.Slice {
+		return reflect.Value{}, reflect.Value{}, false
+	}
+	return oldSlice, newSlice, true
+}
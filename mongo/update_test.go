@@ -0,0 +1,157 @@
+package mongo
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"testing"
+)
+
+type Address struct {
+	City string `bson:"city"`
+}
+
+type Customer struct {
+	Name     string   `bson:"full_name"`
+	Email    string   `json:"email,omitempty"`
+	Tags     []string `bson:"tags"`
+	Notes    []string
+	Address  *Address `bson:"address"`
+	Internal string   `bson:"-"`
+}
+
+func TestUpdateDocumentFromChanges(t *testing.T) {
+	old := Customer{
+		Name:    "Ann",
+		Email:   "ann@example.com",
+		Tags:    []string{"a"},
+		Notes:   []string{"x", "y", "z"},
+		Address: &Address{City: "Utrecht"},
+	}
+	updated := Customer{
+		Name:    "Ann Lee",
+		Tags:    []string{"a", "b", "c"},
+		Notes:   []string{"x", "z"},
+		Address: &Address{City: "Delft"},
+	}
+
+	changes, err := compare.CompareStructsDeep(old, updated)
+	if err != nil {
+		t.Fatalf("CompareStructsDeep failed: %v", err)
+	}
+	document, err := UpdateDocument(updated, changes)
+	if err != nil {
+		t.Fatalf("UpdateDocument failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"$set":   map[string]interface{}{"full_name": "Ann Lee", "address.city": "Delft"},
+		"$unset": map[string]interface{}{"email": ""},
+		"$push":  map[string]interface{}{"tags": map[string]interface{}{"$each": []interface{}{"b", "c"}}},
+		"$pull":  map[string]interface{}{"notes": map[string]interface{}{"$in": []interface{}{"y"}}},
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("Expected %v, got %v", expected, document)
+	}
+}
+
+func TestUpdateDocumentReplacesReorderedSlices(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []string
+	}{
+		{"reordered", []string{"a", "b"}, []string{"b", "a"}},
+		{"inserted in the middle", []string{"a", "c"}, []string{"a", "b", "c"}},
+		{"removed duplicate", []string{"a", "a", "b"}, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		changes := []compare.Change{{Field: "Tags", ChangeType: compare.Modified, OldValue: test.old, NewValue: test.new}}
+		document, err := UpdateDocument(Customer{}, changes)
+		if err != nil {
+			t.Fatalf("%s: UpdateDocument failed: %v", test.name, err)
+		}
+		set, _ := document["$set"].(map[string]interface{})
+		if len(document) != 1 || !reflect.DeepEqual(set["tags"], test.new) {
+			t.Errorf("%s: expected the array to be replaced, got %v", test.name, document)
+		}
+	}
+}
+
+func TestUpdateDocumentElementPaths(t *testing.T) {
+	changes := []compare.Change{{Field: "Tags.1", ChangeType: compare.Modified, OldValue: "b", NewValue: "B"}}
+	document, err := UpdateDocument(&Customer{}, changes)
+	if err != nil {
+		t.Fatalf("UpdateDocument failed: %v", err)
+	}
+	if set, _ := document["$set"].(map[string]interface{}); set["tags.1"] != "B" {
+		t.Errorf("Expected $set on tags.1, got %v", document)
+	}
+}
+
+func TestUpdateDocumentReplacesArrayForDeletedElements(t *testing.T) {
+	updated := Customer{Tags: []string{"a", "c"}}
+	changes := []compare.Change{
+		{Field: "Tags.0", ChangeType: compare.Modified, OldValue: "x", NewValue: "a"},
+		{Field: "Tags.1", ChangeType: compare.Deleted, OldValue: "b"},
+		{Field: "Name", ChangeType: compare.Modified, NewValue: "Ann"},
+	}
+
+	document, err := UpdateDocument(&updated, changes)
+	if err != nil {
+		t.Fatalf("UpdateDocument failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"$set": map[string]interface{}{"tags": []string{"a", "c"}, "full_name": "Ann"},
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("Expected %v, got %v", expected, document)
+	}
+
+	// Entries of maps are still unset
+	type Labeled struct {
+		Labels map[string]string `bson:"labels"`
+	}
+	document, err = UpdateDocument(Labeled{}, []compare.Change{{Field: "Labels.env", ChangeType: compare.Deleted}})
+	if err != nil {
+		t.Fatalf("UpdateDocument failed: %v", err)
+	}
+	if unset, _ := document["$unset"].(map[string]interface{}); len(document) != 1 || unset["labels.env"] != "" {
+		t.Errorf("Expected $unset on labels.env, got %v", document)
+	}
+}
+
+func TestFieldNamePrefersBSONThenJSONTags(t *testing.T) {
+	fields := reflect.TypeOf(struct {
+		Both     string `bson:"both_bson" json:"both_json"`
+		UserName string `json:"user_name"`
+		Options  string `json:",omitempty"`
+		Phone    string
+		Skipped  string `json:"-"`
+	}{})
+
+	for field, expected := range map[string]string{"Both": "both_bson", "UserName": "user_name", "Options": "options", "Phone": "phone"} {
+		structField, _ := fields.FieldByName(field)
+		if name, ok := FieldName(structField); !ok || name != expected {
+			t.Errorf("Expected %s for %s, got %q", expected, field, name)
+		}
+	}
+
+	skipped, _ := fields.FieldByName("Skipped")
+	if _, ok := FieldName(skipped); ok {
+		t.Error("Expected a field tagged json:\"-\" to be skipped")
+	}
+}
+
+func TestUpdateDocumentErrors(t *testing.T) {
+	tests := []string{"Missing", "Internal", "Tags.first", "Name.First"}
+	for _, field := range tests {
+		changes := []compare.Change{{Field: field, ChangeType: compare.Modified, NewValue: "x"}}
+		if _, err := UpdateDocument(Customer{}, changes); err == nil {
+			t.Errorf("Expected error for field %s", field)
+		}
+	}
+	if _, err := UpdateDocument("not a struct", nil); err == nil {
+		t.Error("Expected error for a non-struct target")
+	}
+}
@@ -0,0 +1,195 @@
+package observe
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"sync"
+)
+
+// Filter restricts a subscription to matching changes, with the same semantics as compare.FilterChanges:
+// empty ChangeTypes or Fields match everything
+type Filter struct {
+	ChangeTypes []compare.ChangeType
+	Fields      []string
+}
+
+// Tracked holds a struct value of type T and notifies subscribers with the changes every Update makes.
+//
+// Update hands the mutation a deep copy of the current value made with compare.Clone, so pointers, slices
+// and maps inside it can be modified in place.
+type Tracked[T any] struct {
+	// updateMu serializes updates, mu guards everything else
+	updateMu    sync.Mutex
+	mu          sync.Mutex
+	value       T
+	subscribers map[int]*subscriber
+	nextID      int
+
+	// Notifications waiting to be delivered in the order their updates were stored
+	queue      []notification
+	delivering bool
+}
+
+// subscriber is a registered callback with its filter
+type subscriber struct {
+	filter Filter
+	notify func([]compare.Change)
+}
+
+// notification is the change list of one update with the subscribers registered when it was stored
+type notification struct {
+	changes     []compare.Change
+	subscribers []*subscriber
+}
+
+// New creates a tracked value starting at initial
+func New[T any](initial T) *Tracked[T] {
+	return &Tracked[T]{value: initial, subscribers: make(map[int]*subscriber)}
+}
+
+// Get returns the current value
+func (t *Tracked[T]) Get() T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.value
+}
+
+// Update applies mutate to a copy of the current value, stores the result and notifies subscribers with
+// the changes it made. Updates are applied one at a time, and mutate may call Get but not Update.
+//
+// Subscribers are called outside of any lock, so they may call Get or Update themselves, and they see
+// the change lists of concurrent updates in the order the updates were stored. When another Update is
+// already notifying subscribers, it delivers this update's changes as well and Update returns without
+// waiting for them.
+func (t *Tracked[T]) Update(mutate func(*T)) ([]compare.Change, error) {
+	changes, err := t.update(mutate)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		t.deliver()
+	}
+	return changes, nil
+}
+
+// update - applies mutate and queues the notification for its changes
+func (t *Tracked[T]) update(mutate func(*T)) ([]compare.Change, error) {
+	t.updateMu.Lock()
+	defer t.updateMu.Unlock()
+
+	previous := t.Get()
+	next := compare.Clone(previous)
+	mutate(&next)
+
+	changes, err := compare.CompareStructs(previous, next)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.value = next
+
+	if len(changes) > 0 {
+		subscribers := make([]*subscriber, 0, len(t.subscribers))
+		for _, s := range t.subscribers {
+			subscribers = append(subscribers, s)
+		}
+		t.queue = append(t.queue, notification{changes: changes, subscribers: subscribers})
+	}
+	return changes, nil
+}
+
+// deliver - notifies subscribers of queued changes in order, unless another call is already doing so
+func (t *Tracked[T]) deliver() {
+	t.mu.Lock()
+	if t.delivering {
+		t.mu.Unlock()
+		return
+	}
+	t.delivering = true
+	t.mu.Unlock()
+
+	// A panicking subscriber must not stop later updates from being delivered
+	finished := false
+	defer func() {
+		if !finished {
+			t.mu.Lock()
+			t.delivering = false
+			t.mu.Unlock()
+		}
+	}()
+
+	for {
+		t.mu.Lock()
+		if len(t.queue) == 0 {
+			t.delivering = false
+			t.mu.Unlock()
+			finished = true
+			return
+		}
+		n := t.queue[0]
+		t.queue = t.queue[1:]
+		t.mu.Unlock()
+
+		for _, s := range n.subscribers {
+			if matched := compare.FilterChanges(n.changes, s.filter.ChangeTypes, s.filter.Fields); len(matched) > 0 {
+				s.notify(matched)
+			}
+		}
+	}
+}
+
+// Subscribe registers fn to be called with the changes of every Update that match filter.
+// The returned function removes the subscription.
+func (t *Tracked[T]) Subscribe(filter Filter, fn func([]compare.Change)) (unsubscribe func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := t.nextID
+	t.nextID++
+	t.subscribers[id] = &subscriber{filter: filter, notify: fn}
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subscribers, id)
+	}
+}
+
+// SubscribeChan delivers the changes of every Update that match filter on a channel with the given buffer.
+// Delivery blocks until the change list is received, so the channel must be drained. The returned function
+// removes the subscription and closes the channel.
+func (t *Tracked[T]) SubscribeChan(filter Filter, buffer int) (<-chan []compare.Change, func()) {
+	ch := make(chan []compare.Change, buffer)
+	done := make(chan struct{})
+	var sendMu sync.Mutex
+	var once sync.Once
+
+	remove := t.Subscribe(filter, func(changes []compare.Change) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		select {
+		case ch <- changes:
+		case <-done:
+		}
+	})
+
+	return ch, func() {
+		once.Do(func() {
+			remove()
+			close(done)
+
+			// Wait for an in-flight send to give up before closing the channel
+			sendMu.Lock()
+			close(ch)
+			sendMu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package observe
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"testing"
+	"time"
+)
+
+type Profile struct {
+	Name    string
+	Email   string
+	Age     int
+	Hobbies []string
+}
+
+func TestUpdateReturnsChangesAndStoresValue(t *testing.T) {
+	tracked := New(Profile{Name: "John"})
+
+	changes, err := tracked.Update(func(p *Profile) {
+		p.Name = "Jane"
+		p.Age = 30
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Errorf("Expected 2 changes, got %+v", changes)
+	}
+	if current := tracked.Get(); current.Name != "Jane" || current.Age != 30 {
+		t.Errorf("Expected value to be stored, got %+v", current)
+	}
+}
+
+func TestSubscribeNotifiesWithFilteredChanges(t *testing.T) {
+	tracked := New(Profile{Name: "John"})
+
+	var all, names [][]compare.Change
+	tracked.Subscribe(Filter{}, func(changes []compare.Change) { all = append(all, changes) })
+	tracked.Subscribe(Filter{Fields: []string{"Name"}}, func(changes []compare.Change) { names = append(names, changes) })
+
+	tracked.Update(func(p *Profile) { p.Age = 31 })
+	tracked.Update(func(p *Profile) { p.Name = "Jane"; p.Email = "jane@example.com" })
+
+	if len(all) != 2 {
+		t.Errorf("Expected unfiltered subscriber to be notified twice, got %d", len(all))
+	}
+	if len(names) != 1 || len(names[0]) != 1 || names[0][0].Field != "Name" {
+		t.Errorf("Expected only the Name change to reach the filtered subscriber, got %+v", names)
+	}
+}
+
+func TestSubscribeFiltersByChangeType(t *testing.T) {
+	tracked := New(Profile{Name: "John", Email: "john@example.com"})
+
+	var deleted []compare.Change
+	tracked.Subscribe(Filter{ChangeTypes: []compare.ChangeType{compare.Deleted}}, func(changes []compare.Change) {
+		deleted = append(deleted, changes...)
+	})
+
+	tracked.Update(func(p *Profile) { p.Name = "Jane"; p.Email = "" })
+
+	if len(deleted) != 1 || deleted[0].Field != "Email" {
+		t.Errorf("Expected only the Email deletion, got %+v", deleted)
+	}
+}
+
+func TestUnsubscribeStopsNotifications(t *testing.T) {
+	tracked := New(Profile{})
+
+	calls := 0
+	unsubscribe := tracked.Subscribe(Filter{}, func([]compare.Change) { calls++ })
+	tracked.Update(func(p *Profile) { p.Age = 1 })
+	unsubscribe()
+	tracked.Update(func(p *Profile) { p.Age = 2 })
+
+	if calls != 1 {
+		t.Errorf("Expected 1 notification, got %d", calls)
+	}
+}
+
+func TestNoNotificationWithoutChanges(t *testing.T) {
+	tracked := New(Profile{Name: "John"})
+
+	calls := 0
+	tracked.Subscribe(Filter{}, func([]compare.Change) { calls++ })
+	tracked.Update(func(p *Profile) { p.Name = "John" })
+
+	if calls != 0 {
+		t.Errorf("Expected no notification for a no-op update, got %d", calls)
+	}
+}
+
+func TestSubscribeChanDeliversChanges(t *testing.T) {
+	tracked := New(Profile{})
+
+	ch, unsubscribe := tracked.SubscribeChan(Filter{Fields: []string{"Hobbies"}}, 1)
+	tracked.Update(func(p *Profile) { p.Hobbies = []string{"chess"} })
+
+	select {
+	case changes := <-ch:
+		if len(changes) != 1 || changes[0].ChangeType != compare.Added {
+			t.Errorf("Unexpected changes %+v", changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected changes on the channel")
+	}
+
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed after unsubscribe")
+	}
+	unsubscribe()
+}
+
+func TestUnsubscribeReleasesBlockedUpdate(t *testing.T) {
+	tracked := New(Profile{})
+	_, unsubscribe := tracked.SubscribeChan(Filter{}, 0)
+
+	updated := make(chan struct{})
+	go func() {
+		tracked.Update(func(p *Profile) { p.Age = 1 })
+		close(updated)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	unsubscribe()
+
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Update to return once the subscriber is removed")
+	}
+}
+
+func TestUpdateDetectsChangesThroughPointer(t *testing.T) {
+	original := &Profile{Name: "John"}
+	tracked := New(original)
+
+	changes, err := tracked.Update(func(p **Profile) { (*p).Name = "Jane" })
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Field != "Name" {
+		t.Errorf("Expected Name change, got %+v", changes)
+	}
+	if original.Name != "John" || tracked.Get().Name != "Jane" {
+		t.Errorf("Expected the pointed to struct to be copied, got %q and %q", original.Name, tracked.Get().Name)
+	}
+}
+
+func TestUpdateDetectsChangesMadeInPlace(t *testing.T) {
+	tracked := New(Profile{Name: "John", Hobbies: []string{"chess"}})
+
+	changes, err := tracked.Update(func(p *Profile) { p.Hobbies[0] = "go" })
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Field != "Hobbies" || changes[0].OldValue.([]string)[0] != "chess" {
+		t.Errorf("Expected Hobbies change from chess, got %+v", changes)
+	}
+}
+
+func TestUpdateReleasesLockWhenMutationPanics(t *testing.T) {
+	tracked := New(Profile{Name: "John"})
+
+	func() {
+		defer func() { recover() }()
+		tracked.Update(func(p *Profile) { panic("boom") })
+	}()
+
+	// Get from inside the mutation and later updates must not block
+	if _, err := tracked.Update(func(p *Profile) { p.Age = len(tracked.Get().Name) }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if tracked.Get().Age != 4 {
+		t.Errorf("Expected age 4, got %d", tracked.Get().Age)
+	}
+}
+
+func TestSubscribersSeeUpdatesInOrder(t *testing.T) {
+	tracked := New(Profile{})
+
+	var ages []int
+	tracked.Subscribe(Filter{}, func(changes []compare.Change) {
+		ages = append(ages, changes[0].NewValue.(int))
+
+		// Updates made while notifying are delivered after the current one
+		if len(ages) == 1 {
+			tracked.Update(func(p *Profile) { p.Age = 2 })
+		}
+	})
+
+	tracked.Update(func(p *Profile) { p.Age = 1 })
+	tracked.Update(func(p *Profile) { p.Age = 3 })
+
+	if len(ages) != 3 || ages[0] != 1 || ages[1] != 2 || ages[2] != 3 {
+		t.Errorf("Expected ages delivered as 1, 2, 3, got %v", ages)
+	}
+}
@@ -0,0 +1,410 @@
+// Package protodiff compares and updates messages generated by protoc-gen-go.
+//
+// Generated messages carry internal fields (state, sizeCache, unknownFields, and XXX_ fields in
+// older generated code) that make reflect.DeepEqual and CompareStructs report differences that are not
+// part of the message. This package walks messages through protoreflect instead, so only declared proto
+// fields are compared, field presence decides whether a field was added or deleted, and fields are
+// reported by their proto names.
+package protodiff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Diff compares two messages of the same type and returns the changed fields by their dotted proto
+// path, e.g. "primary.port", in declaration order. Nested messages that are set on both sides are
+// compared field by field; repeated fields, maps and messages that were set or cleared are reported as a
+// whole, matching FieldMask semantics. Fields of a oneof are reported under their own name, so switching
+// a oneof yields a Deleted change for the old field and an Added change for the new one.
+//
+// Scalar values are reported as their Go values and enums by name. Messages are reported in their
+// protojson form decoded into generic JSON values, repeated fields as slices and maps keyed by the text
+// of their keys, so the changes survive compare.ChangesToJSON.
+func Diff(old, new proto.Message) ([]compare.Change, error) {
+	if old == nil || new == nil || !old.ProtoReflect().IsValid() || !new.ProtoReflect().IsValid() {
+		return nil, fmt.Errorf("cannot compare nil messages")
+	}
+	oldMsg, newMsg := old.ProtoReflect(), new.ProtoReflect()
+	if oldMsg.Descriptor().FullName() != newMsg.Descriptor().FullName() {
+		return nil, fmt.Errorf("both messages must be of the same type, got %s and %s",
+			oldMsg.Descriptor().FullName(), newMsg.Descriptor().FullName())
+	}
+
+	changes := make([]compare.Change, 0)
+	if err := diffMessage("", oldMsg, newMsg, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// FieldMask returns the paths of changes without duplicates, in order, ready for the paths of a
+// google.protobuf.FieldMask
+func FieldMask(changes []compare.Change) []string {
+	seen := make(map[string]bool, len(changes))
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if !seen[change.Field] {
+			seen[change.Field] = true
+			paths = append(paths, change.Field)
+		}
+	}
+	return paths
+}
+
+// Equal reports whether two messages are equal, ignoring internal fields and unknown fields
+func Equal(a, b proto.Message) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ma, mb := a.ProtoReflect(), b.ProtoReflect()
+	if ma.IsValid() != mb.IsValid() || ma.Descriptor().FullName() != mb.Descriptor().FullName() {
+		return false
+	}
+	return equalMessage(ma, mb)
+}
+
+// Apply applies changes produced by Diff to msg. Nested messages along a path are allocated when unset,
+// and setting a field of a oneof replaces whichever field the oneof held. Values are accepted as Diff
+// reports them, as generic JSON values after a round trip through compare.ChangesFromJSON, or as
+// generated messages for message fields.
+func Apply(msg proto.Message, changes []compare.Change) error {
+	if msg == nil || !msg.ProtoReflect().IsValid() {
+		return fmt.Errorf("msg must be a non-nil protobuf message, got %T", msg)
+	}
+
+	for _, change := range changes {
+		if err := applyChange(msg.ProtoReflect(), change); err != nil {
+			return fmt.Errorf("field %s: %w", change.Field, err)
+		}
+	}
+	return nil
+}
+
+// diffMessage - appends the changes between two messages of the same type under prefix
+func diffMessage(prefix string, oldMsg, newMsg protoreflect.Message, changes *[]compare.Change) error {
+	fields := oldMsg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		oldSet, newSet := oldMsg.Has(fd), newMsg.Has(fd)
+		path := prefix + string(fd.Name())
+
+		switch {
+		case !oldSet && !newSet:
+			continue
+		case oldSet && newSet:
+			if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+				if err := diffMessage(path+".", oldMsg.Get(fd).Message(), newMsg.Get(fd).Message(), changes); err != nil {
+					return err
+				}
+				continue
+			}
+			if equalField(fd, oldMsg.Get(fd), newMsg.Get(fd)) {
+				continue
+			}
+		}
+
+		change := compare.Change{Field: path, ChangeType: compare.Modified}
+		switch {
+		case !oldSet:
+			change.ChangeType = compare.Added
+		case !newSet:
+			change.ChangeType = compare.Deleted
+		}
+
+		var err error
+		if oldSet {
+			if change.OldValue, err = plainValue(oldMsg, fd); err != nil {
+				return err
+			}
+		}
+		if newSet {
+			if change.NewValue, err = plainValue(newMsg, fd); err != nil {
+				return err
+			}
+		}
+		*changes = append(*changes, change)
+	}
+	return nil
+}
+
+// plainValue - returns the value of field fd of msg as Go scalars, enum names and, for messages, their
+// protojson form decoded into generic JSON values, with repeated fields as slices and maps keyed by text
+func plainValue(msg protoreflect.Message, fd protoreflect.FieldDescriptor) (interface{}, error) {
+	value := msg.Get(fd)
+	switch {
+	case fd.IsList():
+		list := value.List()
+		result := make([]interface{}, list.Len())
+		for i := range result {
+			element, err := plainSingular(fd, list.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = element
+		}
+		return result, nil
+	case fd.IsMap():
+		result := make(map[string]interface{}, value.Map().Len())
+		var err error
+		value.Map().Range(func(key protoreflect.MapKey, element protoreflect.Value) bool {
+			result[key.String()], err = plainSingular(fd.MapValue(), element)
+			return err == nil
+		})
+		return result, err
+	}
+	return plainSingular(fd, value)
+}
+
+// plainSingular - returns a single value of the kind of fd as plainValue reports it
+func plainSingular(fd protoreflect.FieldDescriptor, value protoreflect.Value) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		data, err := protojson.Marshal(value.Message().Interface())
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	case protoreflect.EnumKind:
+		if enum := fd.Enum().Values().ByNumber(value.Enum()); enum != nil {
+			return string(enum.Name()), nil
+		}
+		return int32(value.Enum()), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		// JSON has no NaN or infinities, report them as text that strconv parses back
+		if f := value.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+	}
+	return value.Interface(), nil
+}
+
+// applyChange - applies a single change, walking its proto path from msg
+func applyChange(msg protoreflect.Message, change compare.Change) error {
+	segments := strings.Split(change.Field, ".")
+	for i, segment := range segments {
+		fd := msg.Descriptor().Fields().ByName(protoreflect.Name(segment))
+		if fd == nil {
+			return fmt.Errorf("no proto field %s in %s", segment, msg.Descriptor().FullName())
+		}
+
+		if i < len(segments)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("proto field %s is not a message", segment)
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+
+		if change.ChangeType == compare.Deleted || change.NewValue == nil {
+			// Deleting a oneof field that was already replaced leaves the new field alone
+			if oneof := fd.ContainingOneof(); oneof != nil && msg.WhichOneof(oneof) != fd {
+				return nil
+			}
+			msg.Clear(fd)
+			return nil
+		}
+		return setField(msg, fd, change.NewValue)
+	}
+	return nil
+}
+
+// setField - sets field fd of msg from value, a value reported by Diff or its generic JSON form
+func setField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, value interface{}) error {
+	field := msg.NewField(fd)
+	switch {
+	case fd.IsList():
+		elements, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot convert %T to repeated %s", value, fd.Kind())
+		}
+		list := field.List()
+		for _, element := range elements {
+			converted, err := singularValue(fd, element, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(converted)
+		}
+	case fd.IsMap():
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot convert %T to map of %s", value, fd.MapValue().Kind())
+		}
+		m := field.Map()
+		for text, element := range entries {
+			key, err := singularValue(fd.MapKey(), text, nil)
+			if err != nil {
+				return err
+			}
+			converted, err := singularValue(fd.MapValue(), element, m.NewValue)
+			if err != nil {
+				return err
+			}
+			m.Set(key.MapKey(), converted)
+		}
+	default:
+		converted, err := singularValue(fd, value, func() protoreflect.Value { return field })
+		if err != nil {
+			return err
+		}
+		field = converted
+	}
+	msg.Set(fd, field)
+	return nil
+}
+
+// singularValue - converts a single value to the kind of fd, decoding messages into one returned by
+// newMessage
+func singularValue(fd protoreflect.FieldDescriptor, value interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	invalid := fmt.Errorf("cannot convert %T to %s", value, fd.Kind())
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		message := newMessage()
+		data, err := json.Marshal(value)
+		if m, ok := value.(proto.Message); ok {
+			data, err = protojson.Marshal(m)
+		}
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if err := protojson.Unmarshal(data, message.Message().Interface()); err != nil {
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to %s: %w", value, fd.Message().FullName(), err)
+		}
+		return message, nil
+	case protoreflect.EnumKind:
+		if name, ok := value.(string); ok {
+			enum := fd.Enum().Values().ByName(protoreflect.Name(name))
+			if enum == nil {
+				return protoreflect.Value{}, fmt.Errorf("no value %s in enum %s", name, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(enum.Number()), nil
+		}
+		enum, err := number[int32](value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(enum)), nil
+	case protoreflect.BoolKind:
+		if b, ok := value.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if text, ok := value.(string); ok {
+			return protoreflect.ValueOfString(text), nil
+		}
+	case protoreflect.BytesKind:
+		switch b := value.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			// encoding/json writes byte slices as standard base64
+			decoded, err := base64.StdEncoding.DecodeString(b)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfBytes(decoded), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := number[int32](value)
+		return protoreflect.ValueOfInt32(i), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := number[int64](value)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := number[uint32](value)
+		return protoreflect.ValueOfUint32(u), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := number[uint64](value)
+		return protoreflect.ValueOfUint64(u), err
+	case protoreflect.FloatKind:
+		f, err := number[float32](value)
+		return protoreflect.ValueOfFloat32(f), err
+	case protoreflect.DoubleKind:
+		f, err := number[float64](value)
+		return protoreflect.ValueOfFloat64(f), err
+	}
+	return protoreflect.Value{}, invalid
+}
+
+// number - converts a Go number or decimal text to a number of type T without losing data
+func number[T int32 | int64 | uint32 | uint64 | float32 | float64](value interface{}) (T, error) {
+	var result T
+	converted, ok, err := compare.ConvertNumber(reflect.ValueOf(value), reflect.TypeOf(result))
+	if !ok {
+		return result, fmt.Errorf("cannot convert %T to %T", value, result)
+	}
+	if err != nil {
+		return result, err
+	}
+	return converted.Interface().(T), nil
+}
+
+// equalMessage - compares the populated fields of two messages of the same type
+func equalMessage(a, b protoreflect.Message) bool {
+	fields := a.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if a.Has(fd) != b.Has(fd) {
+			return false
+		}
+		if a.Has(fd) && !equalField(fd, a.Get(fd), b.Get(fd)) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalField - compares two values of field fd
+func equalField(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch {
+	case fd.IsList():
+		la, lb := a.List(), b.List()
+		if la.Len() != lb.Len() {
+			return false
+		}
+		for i := 0; i < la.Len(); i++ {
+			if !equalSingular(fd, la.Get(i), lb.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case fd.IsMap():
+		ma, mb := a.Map(), b.Map()
+		if ma.Len() != mb.Len() {
+			return false
+		}
+		equal := true
+		ma.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			equal = mb.Has(key) && equalSingular(fd.MapValue(), value, mb.Get(key))
+			return equal
+		})
+		return equal
+	}
+	return equalSingular(fd, a, b)
+}
+
+// equalSingular - compares two single values of the kind of fd
+func equalSingular(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return equalMessage(a.Message(), b.Message())
+	case protoreflect.BytesKind:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	}
+	return a.Interface() == b.Interface()
+}
@@ -0,0 +1,183 @@
+package protodiff
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/typepb"
+	"reflect"
+	"testing"
+)
+
+func TestDiffIgnoresInternalFields(t *testing.T) {
+	old := &typepb.Type{Name: "api", SourceContext: &sourcecontextpb.SourceContext{FileName: "api.proto"}}
+	updated := proto.Clone(old).(*typepb.Type)
+	proto.Size(updated)
+	updated.ProtoReflect().SetUnknown([]byte{0xf8, 0x01, 0x01})
+	updated.SourceContext.ProtoReflect().SetUnknown([]byte{0xf8, 0x01, 0x02})
+
+	changes, err := Diff(old, updated)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+	if !Equal(old, updated) {
+		t.Error("Expected messages to be equal")
+	}
+}
+
+func TestDiffUsesProtoNamesAndFieldMask(t *testing.T) {
+	old := &typepb.Type{
+		Name:          "api",
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "a.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+	}
+	updated := &typepb.Type{
+		Name:          "api",
+		Fields:        []*typepb.Field{{Name: "id", Number: 1, Kind: typepb.Field_TYPE_INT64}},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "b.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_EDITIONS,
+		Edition:       "2023",
+	}
+
+	changes, err := Diff(old, updated)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	expected := []string{"fields", "source_context.file_name", "syntax", "edition"}
+	if mask := FieldMask(changes); !reflect.DeepEqual(mask, expected) {
+		t.Errorf("Expected mask %v, got %v", expected, mask)
+	}
+	if changes[0].ChangeType != compare.Added || changes[1].ChangeType != compare.Modified || changes[3].ChangeType != compare.Added {
+		t.Errorf("Unexpected change types %+v", changes)
+	}
+	if changes[2].OldValue != "SYNTAX_PROTO3" || changes[2].NewValue != "SYNTAX_EDITIONS" {
+		t.Errorf("Expected enum names, got %v and %v", changes[2].OldValue, changes[2].NewValue)
+	}
+}
+
+func TestDiffSwitchesOneof(t *testing.T) {
+	changes, err := Diff(structpb.NewStringValue("x"), structpb.NewNumberValue(1))
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Field != "number_value" || changes[0].ChangeType != compare.Added {
+		t.Errorf("Expected number_value to be added, got %+v", changes[0])
+	}
+	if changes[1].Field != "string_value" || changes[1].ChangeType != compare.Deleted {
+		t.Errorf("Expected string_value to be deleted, got %+v", changes[1])
+	}
+
+	if _, err := Diff(structpb.NewStringValue("x"), &typepb.Type{}); err == nil {
+		t.Error("Expected error comparing messages of different types")
+	}
+}
+
+func TestApplyOntoMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     func() proto.Message
+		updated proto.Message
+	}{
+		{
+			name: "type",
+			old: func() proto.Message {
+				return &typepb.Type{Name: "api", Oneofs: []string{"contact"}, Syntax: typepb.Syntax_SYNTAX_PROTO3}
+			},
+			updated: &typepb.Type{
+				Name:          "api-v2",
+				Fields:        []*typepb.Field{{Name: "id", Number: 1, Kind: typepb.Field_TYPE_INT64}},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "api.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_EDITIONS,
+			},
+		},
+		{
+			name: "oneof",
+			old: func() proto.Message {
+				return structpb.NewStringValue("ops@example.com")
+			},
+			updated: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"team":  structpb.NewStringValue("core"),
+				"ports": structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(443)}}),
+			}}),
+		},
+		{
+			name: "nested oneof",
+			old: func() proto.Message {
+				return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+					"team": structpb.NewStringValue("core"),
+				}})
+			},
+			updated: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"team": structpb.NewStringValue("platform"),
+				"tier": structpb.NewNumberValue(1),
+			}}),
+		},
+	}
+
+	for _, test := range tests {
+		changes, err := Diff(test.old(), test.updated)
+		if err != nil {
+			t.Fatalf("%s: Diff failed: %v", test.name, err)
+		}
+
+		// Through JSON, as stored change logs deliver them
+		data, err := compare.ChangesToJSON(changes)
+		if err != nil {
+			t.Fatalf("%s: ChangesToJSON failed: %v", test.name, err)
+		}
+		decoded, err := compare.ChangesFromJSON(data)
+		if err != nil {
+			t.Fatalf("%s: ChangesFromJSON failed: %v", test.name, err)
+		}
+
+		for name, list := range map[string][]compare.Change{"typed": changes, "decoded": decoded} {
+			target := test.old()
+			if err := Apply(target, list); err != nil {
+				t.Fatalf("%s %s: Apply failed: %v", test.name, name, err)
+			}
+			if !Equal(target, test.updated) {
+				t.Errorf("%s %s: unexpected result %s", test.name, name, protojson.Format(target))
+			}
+		}
+	}
+}
+
+func TestApplyNestedPathAllocatesMessages(t *testing.T) {
+	target := &typepb.Type{}
+	err := Apply(target, []compare.Change{{Field: "source_context.file_name", ChangeType: compare.Added, NewValue: "api.proto"}})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if target.SourceContext.GetFileName() != "api.proto" {
+		t.Errorf("Expected source_context.file_name to be set, got %v", target.SourceContext)
+	}
+
+	// Generated messages are accepted for message fields
+	context := &sourcecontextpb.SourceContext{FileName: "b.proto"}
+	if err := Apply(target, []compare.Change{{Field: "source_context", ChangeType: compare.Modified, NewValue: context}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !Equal(target.SourceContext, context) {
+		t.Errorf("Expected source_context %v, got %v", context, target.SourceContext)
+	}
+
+	if err := Apply(target, []compare.Change{{Field: "missing", NewValue: 1}}); err == nil {
+		t.Error("Expected error for an unknown proto field")
+	}
+	if err := Apply(target, []compare.Change{{Field: "name.first", NewValue: "x"}}); err == nil {
+		t.Error("Expected error when descending into a scalar")
+	}
+	if err := Apply(target, []compare.Change{{Field: "syntax", NewValue: float64(1 << 40)}}); err == nil {
+		t.Error("Expected error for an enum number out of the int32 range")
+	}
+}
@@ -0,0 +1,72 @@
+package sqlgen
+
+import (
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+)
+
+// Column is a dirty column and the value to write to it
+type Column struct {
+	Field string
+	Name  string
+	Value interface{}
+}
+
+// Tracker remembers a struct as it was loaded from the database so that later writes only touch the
+// columns that changed. The snapshot is a deep copy made with compare.Clone, so pointers, slices and maps
+// in T can be modified in place.
+type Tracker[T any] struct {
+	snapshot T
+}
+
+// Track snapshots loaded, the value just read from the database
+func Track[T any](loaded T) *Tracker[T] {
+	return &Tracker[T]{snapshot: compare.Clone(loaded)}
+}
+
+// Snapshot returns a copy of the value as last loaded or saved
+func (t *Tracker[T]) Snapshot() T {
+	return compare.Clone(t.snapshot)
+}
+
+// Changes returns the changes between the snapshot and current
+func (t *Tracker[T]) Changes(current T) ([]compare.Change, error) {
+	return compare.CompareStructs(t.snapshot, current)
+}
+
+// Dirty returns the columns of current that differ from the snapshot, in field order. Fields tagged
+// `db:"-"` are never dirty, and an empty result means the write can be skipped.
+func (t *Tracker[T]) Dirty(current T) ([]Column, error) {
+	changes, err := t.Changes(current)
+	if err != nil {
+		return nil, err
+	}
+
+	structType := reflect.TypeOf(current)
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	columns := Columns(structType)
+	dirty := make([]Column, 0, len(changes))
+	for _, change := range inFieldOrder(structType, changes) {
+		if name, ok := columns[change.Field]; ok {
+			dirty = append(dirty, Column{Field: change.Field, Name: name, Value: change.NewValue})
+		}
+	}
+	return dirty, nil
+}
+
+// Update builds the UPDATE statement that writes the dirty columns of current, returning
+// ErrNoChanges when nothing needs to be written
+func (t *Tracker[T]) Update(table string, current T, opts Options) (string, []interface{}, error) {
+	changes, err := t.Changes(current)
+	if err != nil {
+		return "", nil, err
+	}
+	return Update(table, current, changes, opts)
+}
+
+// Saved replaces the snapshot with current once it has been written
+func (t *Tracker[T]) Saved(current T) {
+	t.snapshot = compare.Clone(current)
+}
@@ -0,0 +1,108 @@
+package sqlgen
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrackerReturnsOnlyDirtyColumns(t *testing.T) {
+	tracker := Track(Account{ID: 7, Email: "a@example.com", Balance: 10})
+
+	current := tracker.Snapshot()
+	current.Balance = 20
+	current.Session = "ignored"
+
+	dirty, err := tracker.Dirty(current)
+	if err != nil {
+		t.Fatalf("Dirty failed: %v", err)
+	}
+	if len(dirty) != 1 || dirty[0].Name != "balance" || dirty[0].Field != "Balance" || dirty[0].Value != 20 {
+		t.Errorf("Expected only the balance column, got %+v", dirty)
+	}
+
+	query, args, err := tracker.Update("accounts", current, Options{Key: "account_id"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if query != `UPDATE "accounts" SET "balance" = $1 WHERE "account_id" = $2` || len(args) != 2 {
+		t.Errorf("Unexpected statement %q %v", query, args)
+	}
+}
+
+func TestTrackerSkipsWriteWhenClean(t *testing.T) {
+	tracker := Track(Account{ID: 7, Email: "a@example.com"})
+
+	current := tracker.Snapshot()
+	current.Email = "b@example.com"
+	tracker.Saved(current)
+
+	dirty, err := tracker.Dirty(current)
+	if err != nil {
+		t.Fatalf("Dirty failed: %v", err)
+	}
+	if len(dirty) != 0 {
+		t.Errorf("Expected no dirty columns after saving, got %+v", dirty)
+	}
+	if _, _, err := tracker.Update("accounts", current, Options{Key: "account_id"}); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Expected ErrNoChanges, got %v", err)
+	}
+}
+
+func TestTrackerReportsDirtyColumnsInFieldOrder(t *testing.T) {
+	tracker := Track(Account{ID: 7, Email: "a@example.com", Balance: 10, Status: "new"})
+
+	current := tracker.Snapshot()
+	current.Status = "active"
+	current.Balance = 20
+	current.Email = "b@example.com"
+
+	for i := 0; i < 10; i++ {
+		dirty, err := tracker.Dirty(current)
+		if err != nil {
+			t.Fatalf("Dirty failed: %v", err)
+		}
+		if len(dirty) != 3 || dirty[0].Name != "email" || dirty[1].Name != "balance" || dirty[2].Name != "status" {
+			t.Fatalf("Expected email, balance, status, got %+v", dirty)
+		}
+	}
+}
+
+func TestTrackerCopiesPointedToStruct(t *testing.T) {
+	loaded := &Account{ID: 7, Balance: 10}
+	tracker := Track(loaded)
+
+	// Modifying the loaded value or the returned snapshot must not move the snapshot
+	loaded.Balance = 20
+	tracker.Snapshot().Email = "changed@example.com"
+
+	dirty, err := tracker.Dirty(loaded)
+	if err != nil {
+		t.Fatalf("Dirty failed: %v", err)
+	}
+	if len(dirty) != 1 || dirty[0].Name != "balance" {
+		t.Errorf("Expected the balance column, got %+v", dirty)
+	}
+
+	tracker.Saved(loaded)
+	loaded.Balance = 30
+	if dirty, _ := tracker.Dirty(loaded); len(dirty) != 1 || dirty[0].Value != 30 {
+		t.Errorf("Expected balance dirty after save, got %+v", dirty)
+	}
+}
+
+func TestTrackerDetectsChangesMadeInPlace(t *testing.T) {
+	nickname := "a"
+	loaded := Account{ID: 7, Nickname: &nickname, Tags: []string{"x"}}
+	tracker := Track(loaded)
+
+	*loaded.Nickname = "b"
+	loaded.Tags[0] = "y"
+
+	dirty, err := tracker.Dirty(loaded)
+	if err != nil {
+		t.Fatalf("Dirty failed: %v", err)
+	}
+	if len(dirty) != 2 || dirty[0].Name != "nickname" || dirty[1].Name != "tags" {
+		t.Errorf("Expected the nickname and tags columns, got %+v", dirty)
+	}
+}
@@ -0,0 +1,186 @@
+// Package sqlgen builds SQL statements from change lists, so structs can be persisted with minimal writes
+package sqlgen
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoChanges is returned when a change list leaves no column to update
+var ErrNoChanges = errors.New("no columns to update")
+
+// Placeholder selects how query parameters are written
+type Placeholder int
+
+const (
+	// Dollar writes numbered parameters, $1, $2, ... as used by PostgreSQL and SQLite
+	Dollar Placeholder = iota
+	// Question writes ? for every parameter, as used by MySQL and SQLite
+	Question
+)
+
+// Options controls the UPDATE statement built by Update
+type Options struct {
+	// Placeholder is the parameter style, Dollar by default
+	Placeholder Placeholder
+
+	// Key is the primary key column matched in the WHERE clause, "id" by default
+	Key string
+
+	// OptimisticLock also requires every updated column to still hold the change's OldValue, so the
+	// statement affects no rows when another writer got there first
+	OptimisticLock bool
+}
+
+// Update builds a parameterized `UPDATE table SET col = $1, ... WHERE key = $n` statement from changes
+// made to target. Column names come from `db` struct tags, falling back to the lowercased field name,
+// and fields tagged `db:"-"` are skipped. The primary key value is read from target. Columns are set in
+// struct field order, so the same columns always produce the same statement. A schema-qualified table
+// such as "public.users" has each part quoted.
+func Update(table string, target interface{}, changes []compare.Change, opts Options) (string, []interface{}, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("target must be a struct, got %T", target)
+	}
+
+	columns := Columns(value.Type())
+	key := opts.Key
+	if key == "" {
+		key = "id"
+	}
+	keyField, ok := fieldForColumn(columns, key)
+	if !ok {
+		return "", nil, fmt.Errorf("key column %s not found in %s", key, value.Type())
+	}
+
+	var query strings.Builder
+	args := make([]interface{}, 0, len(changes)+1)
+	param := func(arg interface{}) string {
+		args = append(args, arg)
+		if opts.Placeholder == Question {
+			return "?"
+		}
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	query.WriteString("UPDATE " + quoteTable(table) + " SET ")
+	set := make([]compare.Change, 0, len(changes))
+	for _, change := range inFieldOrder(value.Type(), changes) {
+		column, ok := columns[change.Field]
+		if !ok {
+			if _, exists := value.Type().FieldByName(change.Field); exists {
+				continue
+			}
+			return "", nil, fmt.Errorf("field %s is not a column of %s", change.Field, value.Type())
+		}
+		if column == key {
+			return "", nil, fmt.Errorf("cannot update key column %s", key)
+		}
+
+		if len(set) > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(quoteIdentifier(column) + " = " + param(change.NewValue))
+		set = append(set, change)
+	}
+	if len(set) == 0 {
+		return "", nil, ErrNoChanges
+	}
+
+	query.WriteString(" WHERE " + quoteIdentifier(key) + " = " + param(value.FieldByName(keyField).Interface()))
+	if opts.OptimisticLock {
+		for _, change := range set {
+			column := quoteIdentifier(columns[change.Field])
+			if isNull(change.OldValue) {
+				query.WriteString(" AND " + column + " IS NULL")
+			} else {
+				query.WriteString(" AND " + column + " = " + param(change.OldValue))
+			}
+		}
+	}
+
+	return query.String(), args, nil
+}
+
+// Columns returns the column name of every exported field of a struct type, keyed by field name
+func Columns(t reflect.Type) map[string]string {
+	columns := make(map[string]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		columns[field.Name] = name
+	}
+	return columns
+}
+
+// inFieldOrder - returns a copy of changes sorted by the position of their field in t, with changes to
+// unknown fields last
+func inFieldOrder(t reflect.Type, changes []compare.Change) []compare.Change {
+	index := func(field string) int {
+		if f, ok := t.FieldByName(field); ok {
+			return f.Index[0]
+		}
+		return t.NumField()
+	}
+
+	sorted := append([]compare.Change(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return index(sorted[i].Field) < index(sorted[j].Field)
+	})
+	return sorted
+}
+
+// fieldForColumn - returns the field stored in column
+func fieldForColumn(columns map[string]string, column string) (string, bool) {
+	for field, name := range columns {
+		if name == column {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// quoteIdentifier - quotes a table or column name, doubling embedded quotes
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable - quotes a table name, quoting the schema and table of a qualified name separately
+func quoteTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// isNull - reports whether a value is written as SQL NULL
+func isNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package sqlgen
+
+import (
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"testing"
+)
+
+type Account struct {
+	ID       int64   `db:"account_id"`
+	Email    string  `db:"email"`
+	Balance  int     `db:"balance"`
+	Nickname *string `db:"nickname"`
+	Status   string
+	Session  string   `db:"-"`
+	Tags     []string `db:"tags"`
+}
+
+func TestUpdateBuildsStatementForChangedColumns(t *testing.T) {
+	old := Account{ID: 7, Email: "a@example.com", Balance: 10, Status: "new", Session: "x"}
+	updated := Account{ID: 7, Email: "b@example.com", Balance: 10, Status: "active", Session: "y"}
+
+	changes, err := compare.CompareStructs(old, updated)
+	if err != nil {
+		t.Fatalf("CompareStructs failed: %v", err)
+	}
+
+	query, args, err := Update("accounts", updated, changes, Options{Key: "account_id"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	expected := `UPDATE "accounts" SET "email" = $1, "status" = $2 WHERE "account_id" = $3`
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"b@example.com", "active", int64(7)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestUpdateWithOptimisticLock(t *testing.T) {
+	nickname := "al"
+	changes := []compare.Change{
+		{Field: "Balance", ChangeType: compare.Modified, OldValue: 10, NewValue: 25},
+		{Field: "Nickname", ChangeType: compare.Added, OldValue: (*string)(nil), NewValue: &nickname},
+	}
+
+	query, args, err := Update("accounts", Account{ID: 7}, changes, Options{
+		Key:            "account_id",
+		Placeholder:    Question,
+		OptimisticLock: true,
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	expected := `UPDATE "accounts" SET "balance" = ?, "nickname" = ? WHERE "account_id" = ? AND "balance" = ? AND "nickname" IS NULL`
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if len(args) != 4 || args[0] != 25 || args[2] != int64(7) || args[3] != 10 {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	account := Account{ID: 7}
+
+	_, _, err := Update("accounts", account, nil, Options{Key: "account_id"})
+	if !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Expected ErrNoChanges, got %v", err)
+	}
+
+	_, _, err = Update("accounts", account, []compare.Change{{Field: "Session", NewValue: "x"}}, Options{Key: "account_id"})
+	if !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Expected skipped columns to leave nothing to update, got %v", err)
+	}
+
+	_, _, err = Update("accounts", account, []compare.Change{{Field: "Email", NewValue: "x"}}, Options{})
+	if err == nil {
+		t.Error("Expected error for a missing key column")
+	}
+
+	_, _, err = Update("accounts", account, []compare.Change{{Field: "ID", NewValue: int64(8)}}, Options{Key: "account_id"})
+	if err == nil {
+		t.Error("Expected error when updating the key column")
+	}
+
+	_, _, err = Update("accounts", account, []compare.Change{{Field: "Missing", NewValue: 1}}, Options{Key: "account_id"})
+	if err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+}
+
+func TestUpdateSetsColumnsInFieldOrder(t *testing.T) {
+	changes := []compare.Change{
+		{Field: "Status", ChangeType: compare.Modified, NewValue: "active"},
+		{Field: "Balance", ChangeType: compare.Modified, NewValue: 5},
+		{Field: "Email", ChangeType: compare.Modified, NewValue: "b@example.com"},
+	}
+
+	query, args, err := Update("accounts", Account{ID: 7}, changes, Options{Key: "account_id"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	expected := `UPDATE "accounts" SET "email" = $1, "balance" = $2, "status" = $3 WHERE "account_id" = $4`
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"b@example.com", 5, "active", int64(7)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestUpdateQuotesSchemaQualifiedTables(t *testing.T) {
+	changes := []compare.Change{{Field: "Email", ChangeType: compare.Modified, NewValue: "b@example.com"}}
+
+	query, _, err := Update("public.users", Account{ID: 7}, changes, Options{Key: "account_id"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	expected := `UPDATE "public"."users" SET "email" = $1 WHERE "account_id" = $2`
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if quoted := quoteTable(`my"schema.users`); quoted != `"my""schema"."users"` {
+		t.Errorf("Expected embedded quotes to be doubled, got %s", quoted)
+	}
+}
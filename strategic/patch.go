@@ -0,0 +1,453 @@
+// Package strategic creates and applies strategic merge patches, in which lists of structs are merged
+// element by element using a merge key instead of being replaced as a whole
+package strategic
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/change"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"reflect"
+	"strings"
+)
+
+// Directive is the key of a list element that carries a patch directive
+const Directive = "$patch"
+
+// DeleteDirective removes the list element with the same merge key
+const DeleteDirective = "delete"
+
+// CreatePatch returns the strategic merge patch that turns old into new, keyed by json field names.
+//
+// Changed fields hold their new value and removed fields hold nil. Nested structs are patched field by
+// field and maps with string keys entry by entry. Slices of structs whose field is tagged with a merge key, `sync:"mergeKey=name"` or the
+// Kubernetes `patchStrategy:"merge" patchMergeKey:"name"`, list only the elements that were added or
+// changed, each carrying its merge key, plus a `{"name": ..., "$patch": "delete"}` entry per removed
+// element. Elements are identified by key alone, so reordering a keyed list is not part of the patch.
+func CreatePatch(old, new interface{}) (map[string]interface{}, error) {
+	changes, err := compare.CompareStructs(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	oldVal, newVal := indirect(reflect.ValueOf(old)), indirect(reflect.ValueOf(new))
+	patch := make(map[string]interface{}, len(changes))
+	for _, c := range changes {
+		field, _ := newVal.Type().FieldByName(c.Field)
+		name, ok := compare.JSONFieldName(field)
+		if !ok {
+			continue
+		}
+		value, err := diffField(field, oldVal.FieldByName(c.Field), newVal.FieldByName(c.Field))
+		if err != nil {
+			return nil, err
+		}
+		patch[name] = value
+	}
+	return patch, nil
+}
+
+// ApplyPatch applies a strategic merge patch to target and returns a modified copy. The patch may come
+// from CreatePatch or be decoded from JSON. `$setElementOrder/` directives are accepted and ignored.
+func ApplyPatch(target interface{}, patch map[string]interface{}) (interface{}, error) {
+	targetVal := indirect(reflect.ValueOf(target))
+	if targetVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("target must be a struct, got %T", target)
+	}
+
+	changes := make([]compare.Change, 0, len(patch))
+	for key, raw := range patch {
+		if strings.HasPrefix(key, "$setElementOrder/") {
+			continue
+		}
+		field, ok := fieldByJSONName(targetVal.Type(), key)
+		if !ok {
+			return nil, fmt.Errorf("field %s not found in %s", key, targetVal.Type())
+		}
+
+		current := targetVal.FieldByIndex(field.Index)
+		merged, err := mergeField(field, current, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		c := compare.Change{Field: field.Name, ChangeType: compare.Modified, OldValue: current.Interface(), NewValue: merged.Interface()}
+		if raw == nil {
+			c.ChangeType = compare.Deleted
+		}
+		changes = append(changes, c)
+	}
+	return change.ApplyChanges(target, changes)
+}
+
+// MergeKey returns the merge key of a slice field, and false when the field is not merged by key
+func MergeKey(field reflect.StructField) (string, bool) {
+	if key, ok := compare.SyncOption(field, "mergeKey"); ok && key != "" {
+		return key, true
+	}
+	if strings.Contains(field.Tag.Get("patchStrategy"), "merge") {
+		if key := field.Tag.Get("patchMergeKey"); key != "" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// diffField - returns the patch value that turns oldVal into newVal
+func diffField(field reflect.StructField, oldVal, newVal reflect.Value) (interface{}, error) {
+	if isNil(newVal) {
+		return nil, nil
+	}
+
+	if key, ok := MergeKey(field); ok && newVal.Kind() == reflect.Slice && isPatchable(newVal.Type().Elem()) {
+		return diffList(key, oldVal, newVal)
+	}
+	if isPatchable(newVal.Type()) && !isNil(oldVal) {
+		return diffStruct(indirect(oldVal), indirect(newVal))
+	}
+	if newVal.Kind() == reflect.Map && newVal.Type().Key().Kind() == reflect.String && !isNil(oldVal) {
+		return diffMap(oldVal, newVal), nil
+	}
+	return newVal.Interface(), nil
+}
+
+// diffStruct - returns the patch of the fields that differ between two structs
+func diffStruct(oldVal, newVal reflect.Value) (map[string]interface{}, error) {
+	patch := make(map[string]interface{})
+	for i := 0; i < newVal.NumField(); i++ {
+		field := newVal.Type().Field(i)
+		name, ok := compare.JSONFieldName(field)
+		if !field.IsExported() || !ok {
+			continue
+		}
+		if reflect.DeepEqual(oldVal.Field(i).Interface(), newVal.Field(i).Interface()) {
+			continue
+		}
+
+		value, err := diffField(field, oldVal.Field(i), newVal.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		patch[name] = value
+	}
+	return patch, nil
+}
+
+// diffList - returns the patch list of a slice merged by key
+func diffList(key string, oldVal, newVal reflect.Value) ([]interface{}, error) {
+	oldElements, err := elementsByKey(key, oldVal)
+	if err != nil {
+		return nil, err
+	}
+	newElements, err := elementsByKey(key, newVal)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make([]interface{}, 0)
+	for i := 0; i < newVal.Len(); i++ {
+		element := newVal.Index(i)
+		id, keyValue, _ := elementKey(key, element)
+
+		previous, ok := oldElements[id]
+		switch {
+		case !ok:
+			patch = append(patch, element.Interface())
+		case !reflect.DeepEqual(previous.Interface(), element.Interface()):
+			elementPatch, err := diffStruct(indirect(previous), indirect(element))
+			if err != nil {
+				return nil, err
+			}
+			elementPatch[key] = keyValue
+			patch = append(patch, elementPatch)
+		}
+	}
+
+	for i := 0; i < oldVal.Len(); i++ {
+		id, keyValue, _ := elementKey(key, oldVal.Index(i))
+		if _, ok := newElements[id]; !ok {
+			patch = append(patch, map[string]interface{}{key: keyValue, Directive: DeleteDirective})
+		}
+	}
+	return patch, nil
+}
+
+// mergeField - returns the value of a field after merging raw into current
+func mergeField(field reflect.StructField, current reflect.Value, raw interface{}) (reflect.Value, error) {
+	if raw == nil {
+		return reflect.Zero(field.Type), nil
+	}
+
+	if key, ok := MergeKey(field); ok && field.Type.Kind() == reflect.Slice && isPatchable(field.Type.Elem()) {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("field %s: expected a list patch, got %T", field.Name, raw)
+		}
+		return mergeList(key, current, list)
+	}
+
+	if patch, ok := raw.(map[string]interface{}); ok {
+		if isPatchable(field.Type) {
+			return mergeStruct(current, patch)
+		}
+		if field.Type.Kind() == reflect.Map && field.Type.Key().Kind() == reflect.String {
+			return mergeMap(current, patch)
+		}
+	}
+	return decode(raw, field.Type)
+}
+
+// mergeStruct - returns a copy of a struct, or of the struct a pointer refers to, with patch merged in
+func mergeStruct(current reflect.Value, patch map[string]interface{}) (reflect.Value, error) {
+	structType := current.Type()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	result := reflect.New(structType).Elem()
+	if !isNil(current) {
+		result.Set(indirect(current))
+	}
+
+	for key, raw := range patch {
+		if key == Directive || strings.HasPrefix(key, "$setElementOrder/") {
+			continue
+		}
+		field, ok := fieldByJSONName(structType, key)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("field %s not found in %s", key, structType)
+		}
+
+		merged, err := mergeField(field, result.FieldByIndex(field.Index), raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.FieldByIndex(field.Index).Set(merged)
+	}
+
+	if current.Kind() == reflect.Ptr {
+		return result.Addr(), nil
+	}
+	return result, nil
+}
+
+// mergeList - returns a copy of a slice merged by key with a patch list. Patched elements stay in place
+// and new elements are appended.
+func mergeList(key string, current reflect.Value, list []interface{}) (reflect.Value, error) {
+	result := reflect.MakeSlice(current.Type(), 0, current.Len()+len(list))
+	result = reflect.AppendSlice(result, current)
+
+	for _, raw := range list {
+		// A whole element, as CreatePatch lists new elements, replaces the element with its key
+		patch, ok := raw.(map[string]interface{})
+		if !ok {
+			element, err := decode(raw, current.Type().Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			id, _, err := elementKey(key, element)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if index := indexOf(key, id, result); index >= 0 {
+				result.Index(index).Set(element)
+			} else {
+				result = reflect.Append(result, element)
+			}
+			continue
+		}
+
+		keyValue, ok := patch[key]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("list element is missing merge key %s", key)
+		}
+		id, err := keyID(keyValue)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		index := indexOf(key, id, result)
+
+		switch directive := patch[Directive]; directive {
+		case DeleteDirective:
+			if index >= 0 {
+				result = reflect.AppendSlice(result.Slice(0, index), result.Slice(index+1, result.Len()))
+			}
+			continue
+		case nil:
+		default:
+			return reflect.Value{}, fmt.Errorf("unsupported patch directive %v", directive)
+		}
+
+		if index < 0 {
+			element, err := mergeStruct(reflect.Zero(current.Type().Elem()), patch)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result = reflect.Append(result, element)
+			continue
+		}
+
+		element, err := mergeStruct(result.Index(index), patch)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.Index(index).Set(element)
+	}
+	return result, nil
+}
+
+// indexOf - returns the index of the element with merge key id, -1 if there is none
+func indexOf(key, id string, slice reflect.Value) int {
+	for i := 0; i < slice.Len(); i++ {
+		if elementID, _, err := elementKey(key, slice.Index(i)); err == nil && elementID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeMap - returns a copy of a map with patch merged in, nil entries removing keys
+func mergeMap(current reflect.Value, patch map[string]interface{}) (reflect.Value, error) {
+	result := reflect.MakeMapWithSize(current.Type(), current.Len()+len(patch))
+	iter := current.MapRange()
+	for iter.Next() {
+		result.SetMapIndex(iter.Key(), iter.Value())
+	}
+
+	for key, raw := range patch {
+		mapKey := reflect.ValueOf(key).Convert(current.Type().Key())
+		if raw == nil {
+			result.SetMapIndex(mapKey, reflect.Value{})
+			continue
+		}
+		value, err := decode(raw, current.Type().Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetMapIndex(mapKey, value)
+	}
+	return result, nil
+}
+
+// diffMap - returns the patch of the entries that differ between two maps, removed entries as nil
+func diffMap(oldVal, newVal reflect.Value) map[string]interface{} {
+	patch := make(map[string]interface{})
+	iter := newVal.MapRange()
+	for iter.Next() {
+		previous := oldVal.MapIndex(iter.Key())
+		if !previous.IsValid() || !reflect.DeepEqual(previous.Interface(), iter.Value().Interface()) {
+			patch[iter.Key().String()] = iter.Value().Interface()
+		}
+	}
+
+	iter = oldVal.MapRange()
+	for iter.Next() {
+		if !newVal.MapIndex(iter.Key()).IsValid() {
+			patch[iter.Key().String()] = nil
+		}
+	}
+	return patch
+}
+
+// elementsByKey - indexes the elements of a slice by their merge key
+func elementsByKey(key string, slice reflect.Value) (map[string]reflect.Value, error) {
+	elements := make(map[string]reflect.Value, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		id, _, err := elementKey(key, slice.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := elements[id]; ok {
+			return nil, fmt.Errorf("duplicate merge key %s %s", key, id)
+		}
+		elements[id] = slice.Index(i)
+	}
+	return elements, nil
+}
+
+// elementKey - returns the merge key of a list element, both as a comparable ID and as its value
+func elementKey(key string, element reflect.Value) (string, interface{}, error) {
+	if isNil(element) {
+		return "", nil, fmt.Errorf("nil list element has no merge key %s", key)
+	}
+	element = indirect(element)
+
+	field, ok := fieldByJSONName(element.Type(), key)
+	if !ok {
+		return "", nil, fmt.Errorf("merge key %s not found in %s", key, element.Type())
+	}
+	value := element.FieldByIndex(field.Index).Interface()
+	id, err := keyID(value)
+	return id, value, err
+}
+
+// keyID - returns the JSON encoding of a merge key, so typed and decoded keys compare equal
+func keyID(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid merge key: %w", err)
+	}
+	return string(data), nil
+}
+
+// fieldByJSONName - finds the exported field encoded under a json key
+func fieldByJSONName(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, ok := compare.JSONFieldName(field); ok && field.IsExported() && name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// isPatchable - reports whether values of t are patched field by field, which holds for structs and
+// pointers to structs with exported fields
+func isPatchable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// decode - converts a patch value into t, going through JSON for decoded values
+func decode(raw interface{}, t reflect.Type) (reflect.Value, error) {
+	if value := reflect.ValueOf(raw); value.Type().AssignableTo(t) {
+		return value, nil
+	}
+
+	target := reflect.New(t)
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := json.Unmarshal(data, target.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot decode value into %s: %w", t, err)
+	}
+	return target.Elem(), nil
+}
+
+// indirect - follows pointers to the value they refer to
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// isNil - reports whether a value is a nil pointer, interface, slice or map
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return !v.IsValid()
+}
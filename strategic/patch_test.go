@@ -0,0 +1,146 @@
+package strategic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type Port struct {
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Ports []Port `json:"ports,omitempty" patchStrategy:"merge" patchMergeKey:"containerPort"`
+}
+
+type PodSpec struct {
+	Labels     map[string]string `json:"labels,omitempty"`
+	Containers []Container       `json:"containers" sync:"mergeKey=name"`
+	Volumes    []string          `json:"volumes,omitempty"`
+	Replicas   *int              `json:"replicas,omitempty"`
+}
+
+func testSpec() PodSpec {
+	return PodSpec{
+		Labels: map[string]string{"app": "web", "tier": "front"},
+		Containers: []Container{
+			{Name: "web", Image: "nginx:1.25", Ports: []Port{{ContainerPort: 80}, {ContainerPort: 443}}},
+			{Name: "sidecar", Image: "envoy:1.0"},
+		},
+		Volumes: []string{"data"},
+	}
+}
+
+func TestCreatePatchMergesListsByKey(t *testing.T) {
+	old := testSpec()
+	updated := testSpec()
+	updated.Labels = map[string]string{"app": "web", "version": "2"}
+	updated.Containers = []Container{
+		{Name: "web", Image: "nginx:1.26", Ports: []Port{{ContainerPort: 80}, {ContainerPort: 8080}}},
+		{Name: "metrics", Image: "exporter:3"},
+	}
+
+	patch, err := CreatePatch(old, updated)
+	if err != nil {
+		t.Fatalf("CreatePatch failed: %v", err)
+	}
+
+	data, _ := json.Marshal(patch)
+	expected := `{"containers":[` +
+		`{"image":"nginx:1.26","name":"web","ports":[{"containerPort":8080},{"$patch":"delete","containerPort":443}]},` +
+		`{"name":"metrics","image":"exporter:3"},` +
+		`{"$patch":"delete","name":"sidecar"}],` +
+		`"labels":{"tier":null,"version":"2"}}`
+	if string(data) != expected {
+		t.Errorf("Expected patch\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestApplyPatchRoundTripsThroughJSON(t *testing.T) {
+	old := testSpec()
+	replicas := 3
+	updated := testSpec()
+	updated.Replicas = &replicas
+	updated.Volumes = nil
+	updated.Containers = []Container{
+		{Name: "web", Image: "nginx:1.25", Ports: []Port{{ContainerPort: 80, Protocol: "TCP"}}},
+		{Name: "sidecar", Image: "envoy:1.1"},
+		{Name: "metrics", Image: "exporter:3"},
+	}
+
+	patch, err := CreatePatch(old, updated)
+	if err != nil {
+		t.Fatalf("CreatePatch failed: %v", err)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	for name, p := range map[string]map[string]interface{}{"typed": patch, "decoded": decoded} {
+		result, err := ApplyPatch(old, p)
+		if err != nil {
+			t.Fatalf("%s: ApplyPatch failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(result, updated) {
+			t.Errorf("%s: expected %+v, got %+v", name, updated, result)
+		}
+	}
+}
+
+func TestApplyKubernetesStylePatch(t *testing.T) {
+	patch := map[string]interface{}{}
+	err := json.Unmarshal([]byte(`{
+		"$setElementOrder/containers": [{"name": "web"}, {"name": "sidecar"}],
+		"containers": [{"name": "web", "ports": [{"containerPort": 443, "$patch": "delete"}]}],
+		"labels": {"tier": null}
+	}`), &patch)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	original := testSpec()
+	result, err := ApplyPatch(&original, patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+
+	spec := result.(*PodSpec)
+	if len(spec.Containers) != 2 || spec.Containers[0].Image != "nginx:1.25" || len(spec.Containers[0].Ports) != 1 {
+		t.Errorf("Unexpected containers %+v", spec.Containers)
+	}
+	if len(spec.Labels) != 1 || spec.Labels["app"] != "web" {
+		t.Errorf("Unexpected labels %v", spec.Labels)
+	}
+	if len(original.Containers[0].Ports) != 2 || len(original.Labels) != 2 {
+		t.Errorf("Expected the original to be unchanged, got %+v", original)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":       `{"missing": 1}`,
+		"missing merge key":   `{"containers": [{"image": "x"}]}`,
+		"unknown directive":   `{"containers": [{"name": "web", "$patch": "replace"}]}`,
+		"list patch required": `{"containers": {"name": "web"}}`,
+	}
+
+	for name, data := range tests {
+		var patch map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &patch); err != nil {
+			t.Fatalf("%s: Unmarshal failed: %v", name, err)
+		}
+		if _, err := ApplyPatch(testSpec(), patch); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
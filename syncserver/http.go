@@ -0,0 +1,423 @@
+package syncserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/change"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrConflict is returned by Client.Push when the server has moved past the client's version
+var ErrConflict = errors.New("syncserver: version conflict")
+
+// errGone is returned by requests for change sets the server no longer keeps
+var errGone = errors.New("syncserver: change sets no longer available")
+
+// maxWait caps how long the server holds a long-poll request open
+const maxWait = 60 * time.Second
+
+// maxPushSize bounds the body of a pushed change set
+const maxPushSize = 16 << 20
+
+// DefaultHistory is the number of change sets a server created by NewServer keeps
+const DefaultHistory = 1000
+
+// Options configures a Server
+type Options struct {
+	// History is the number of most recent change sets kept for clients to catch up from. Clients
+	// further behind fall back to the full state. 0 means DefaultHistory.
+	History int
+}
+
+// stateResponse is the body of GET /state
+type stateResponse struct {
+	Version uint64          `json:"version"`
+	State   json.RawMessage `json:"state"`
+}
+
+// changesResponse is the body of GET /changes
+type changesResponse struct {
+	Version    uint64              `json:"version"`
+	ChangeSets []compare.ChangeSet `json:"change_sets"`
+}
+
+// Server holds the authoritative state of a struct of type T and serves it over HTTP:
+//
+//	GET  /state                      current version and full state
+//	GET  /changes?since=N&wait=30s   change sets after version N, long-polling up to wait for new ones
+//	POST /changes                    a compare.ChangeSet whose ParentVersion must equal the current version
+//
+// A push with a stale ParentVersion is rejected with 409 Conflict, and one recorded against another type
+// or schema with 422 Unprocessable Entity. Only the most recent change sets are kept; a request for
+// changes since an older version gets 410 Gone and must fetch the full state.
+type Server[T any] struct {
+	mu         sync.Mutex
+	value      T
+	current    uint64
+	changeSets []compare.ChangeSet
+	history    int
+
+	// changed is closed and replaced whenever a new version is committed, waking long-polls
+	changed chan struct{}
+	mux     *http.ServeMux
+}
+
+// NewServer creates a server holding initial at version 0
+func NewServer[T any](initial T) *Server[T] {
+	return NewServerWith(initial, Options{})
+}
+
+// NewServerWith creates a server holding initial at version 0, configured by options
+func NewServerWith[T any](initial T, options Options) *Server[T] {
+	if options.History <= 0 {
+		options.History = DefaultHistory
+	}
+
+	s := &Server[T]{value: initial, history: options.History, changed: make(chan struct{}), mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /state", s.handleState)
+	s.mux.HandleFunc("GET /changes", s.handleChanges)
+	s.mux.HandleFunc("POST /changes", s.handlePush)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// State returns the current value and its version
+func (s *Server[T]) State() (T, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value, s.version()
+}
+
+// Apply applies changes on the server side and publishes them to clients as the next version
+func (s *Server[T]) Apply(changes []compare.Change, author string) (compare.ChangeSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, err := compare.NewChangeSet(s.value, changes)
+	if err != nil {
+		return compare.ChangeSet{}, err
+	}
+	cs.Author = author
+	cs.ParentVersion = s.version()
+	return s.commit(cs)
+}
+
+// version - returns the current version, the caller must hold the lock
+func (s *Server[T]) version() uint64 {
+	return s.current
+}
+
+// commit - applies cs as the next version and wakes waiting long-polls, the caller must hold the lock
+func (s *Server[T]) commit(cs compare.ChangeSet) (compare.ChangeSet, error) {
+	if err := cs.AppliesTo(s.value); err != nil {
+		return compare.ChangeSet{}, err
+	}
+
+	changes, err := compare.TypedChanges(s.value, cs.Changes)
+	if err != nil {
+		return compare.ChangeSet{}, err
+	}
+	result, err := change.ApplyChanges(s.value, changes)
+	if err != nil {
+		return compare.ChangeSet{}, err
+	}
+
+	s.value = result.(T)
+	cs.Changes = changes
+	cs.Version = s.version() + 1
+	s.current = cs.Version
+	s.changeSets = append(s.changeSets, cs)
+
+	// Drop the oldest change sets, copying so the dropped ones can be collected
+	if len(s.changeSets) > s.history {
+		s.changeSets = append([]compare.ChangeSet(nil), s.changeSets[len(s.changeSets)-s.history:]...)
+	}
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return cs, nil
+}
+
+// since - returns the change sets after version and a channel closed on the next commit, and false when
+// the change sets following version are no longer kept
+func (s *Server[T]) since(version uint64) (uint64, []compare.ChangeSet, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Versions are contiguous, so the kept change sets start right after oldest
+	oldest := s.current - uint64(len(s.changeSets))
+	if version < oldest {
+		return s.current, nil, s.changed, false
+	}
+
+	var changeSets []compare.ChangeSet
+	if version < s.current {
+		changeSets = append(changeSets, s.changeSets[version-oldest:]...)
+	}
+	return s.current, changeSets, s.changed, true
+}
+
+// handleState - serves the full state
+func (s *Server[T]) handleState(w http.ResponseWriter, r *http.Request) {
+	value, version := s.State()
+
+	state, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stateResponse{Version: version, State: state})
+}
+
+// handleChanges - serves change sets since a version, long-polling when there are none yet
+func (s *Server[T]) handleChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid since parameter", http.StatusBadRequest)
+		return
+	}
+
+	var wait time.Duration
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		if wait, err = time.ParseDuration(raw); err != nil || wait < 0 {
+			http.Error(w, "invalid wait parameter", http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, maxWait)
+	}
+
+	version, changeSets, changed, ok := s.since(since)
+	if since > version {
+		http.Error(w, fmt.Sprintf("version %d is ahead of the server at %d", since, version), http.StatusConflict)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("change sets since version %d are no longer available", since), http.StatusGone)
+		return
+	}
+
+	if len(changeSets) == 0 && wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-changed:
+			version, changeSets, _, _ = s.since(since)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, changesResponse{Version: version, ChangeSets: changeSets})
+}
+
+// handlePush - accepts a change set built against the current version
+func (s *Server[T]) handlePush(w http.ResponseWriter, r *http.Request) {
+	var cs compare.ChangeSet
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushSize)).Decode(&cs); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "change set too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid change set: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if cs.ParentVersion != s.version() {
+		version := s.version()
+		s.mu.Unlock()
+		http.Error(w, fmt.Sprintf("parent version %d does not match current version %d", cs.ParentVersion, version), http.StatusConflict)
+		return
+	}
+	committed, err := s.commit(cs)
+	s.mu.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, http.StatusOK, committed)
+}
+
+// writeJSON - writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Client keeps a local copy of a Server's state up to date
+type Client[T any] struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	value   T
+	version uint64
+}
+
+// NewClient creates a client for the server at baseURL. A nil httpClient uses http.DefaultClient.
+func NewClient[T any](baseURL string, httpClient *http.Client) *Client[T] {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client[T]{baseURL: baseURL, httpClient: httpClient}
+}
+
+// State returns the local value and the server version it corresponds to
+func (c *Client[T]) State() (T, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value, c.version
+}
+
+// Fetch replaces the local value with the server's full state
+func (c *Client[T]) Fetch(ctx context.Context) error {
+	var response stateResponse
+	if err := c.do(ctx, http.MethodGet, "/state", nil, &response); err != nil {
+		return err
+	}
+
+	var value T
+	if err := json.Unmarshal(response.State, &value); err != nil {
+		return fmt.Errorf("cannot decode state: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value, c.version = value, response.Version
+	return nil
+}
+
+// Poll fetches the change sets since the local version, waiting up to wait for new ones to arrive,
+// and applies them locally. It returns the number of change sets applied. When the server no longer
+// keeps the change sets since the local version, Poll fetches the full state instead and returns 0.
+func (c *Client[T]) Poll(ctx context.Context, wait time.Duration) (int, error) {
+	_, since := c.State()
+
+	query := url.Values{"since": {strconv.FormatUint(since, 10)}}
+	if wait > 0 {
+		query.Set("wait", wait.String())
+	}
+
+	var response changesResponse
+	err := c.do(ctx, http.MethodGet, "/changes?"+query.Encode(), nil, &response)
+	if errors.Is(err, errGone) {
+		return 0, c.Fetch(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	applied := 0
+	for _, cs := range response.ChangeSets {
+		// Skip change sets a concurrent Push or Poll already applied
+		if cs.Version <= c.version {
+			continue
+		}
+		if err := c.apply(cs); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// Push sends changes to the server as the next version after the local one and applies them locally
+// once accepted. ErrConflict means the server has newer changes; Poll and retry.
+func (c *Client[T]) Push(ctx context.Context, changes []compare.Change) (compare.ChangeSet, error) {
+	c.mu.Lock()
+	cs, err := compare.NewChangeSet(c.value, changes)
+	cs.ParentVersion = c.version
+	c.mu.Unlock()
+	if err != nil {
+		return compare.ChangeSet{}, err
+	}
+
+	body, err := compare.ChangeSetToJSON(cs)
+	if err != nil {
+		return compare.ChangeSet{}, err
+	}
+
+	var committed compare.ChangeSet
+	if err := c.do(ctx, http.MethodPost, "/changes", body, &committed); err != nil {
+		return compare.ChangeSet{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if committed.Version > c.version {
+		if err := c.apply(committed); err != nil {
+			return committed, err
+		}
+	}
+	return committed, nil
+}
+
+// apply - applies a change set received from the server, the caller must hold the lock
+func (c *Client[T]) apply(cs compare.ChangeSet) error {
+	if cs.ParentVersion != c.version {
+		return fmt.Errorf("change set %s continues version %d, local version is %d", cs.ID, cs.ParentVersion, c.version)
+	}
+
+	changes, err := compare.TypedChanges(c.value, cs.Changes)
+	if err != nil {
+		return err
+	}
+	result, err := change.ApplyChanges(c.value, changes)
+	if err != nil {
+		return fmt.Errorf("cannot apply change set %s: %w", cs.ID, err)
+	}
+
+	c.value = result.(T)
+	c.version = cs.Version
+	return nil
+}
+
+// do - performs a request against the server and decodes the JSON response into out
+func (c *Client[T]) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusConflict {
+		return ErrConflict
+	}
+	if response.StatusCode == http.StatusGone {
+		return errGone
+	}
+	if response.StatusCode != http.StatusOK {
+		var message bytes.Buffer
+		message.ReadFrom(response.Body)
+		return fmt.Errorf("syncserver: %s %s: %s: %s", method, path, response.Status, bytes.TrimSpace(message.Bytes()))
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
@@ -0,0 +1,220 @@
+package syncserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type Board struct {
+	Title   string
+	Columns []string
+	Limit   int
+}
+
+func newTestServer(t *testing.T) (*Server[Board], *httptest.Server) {
+	t.Helper()
+	server := NewServer(Board{Title: "Sprint", Columns: []string{"todo"}})
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+func TestClientFetchesState(t *testing.T) {
+	_, httpServer := newTestServer(t)
+	client := NewClient[Board](httpServer.URL, nil)
+
+	if err := client.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	value, version := client.State()
+	if value.Title != "Sprint" || len(value.Columns) != 1 || version != 0 {
+		t.Errorf("Unexpected state %+v at version %d", value, version)
+	}
+}
+
+func TestClientPollsServerChanges(t *testing.T) {
+	server, httpServer := newTestServer(t)
+	client := NewClient[Board](httpServer.URL, nil)
+	client.Fetch(context.Background())
+
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 5}}, "admin")
+	server.Apply([]compare.Change{{Field: "Columns", ChangeType: compare.Modified, NewValue: []string{"todo", "done"}}}, "admin")
+
+	applied, err := client.Poll(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	value, version := client.State()
+	if applied != 2 || version != 2 || value.Limit != 5 || len(value.Columns) != 2 {
+		t.Errorf("Expected both change sets applied, got %+v at version %d", value, version)
+	}
+}
+
+func TestClientLongPollWaitsForChanges(t *testing.T) {
+	server, httpServer := newTestServer(t)
+	client := NewClient[Board](httpServer.URL, nil)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		server.Apply([]compare.Change{{Field: "Title", ChangeType: compare.Modified, NewValue: "Release"}}, "admin")
+	}()
+
+	applied, err := client.Poll(context.Background(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if value, _ := client.State(); applied != 1 || value.Title != "Release" {
+		t.Errorf("Expected the change made during the long-poll, got %+v", value)
+	}
+}
+
+func TestClientLongPollTimesOutWithoutChanges(t *testing.T) {
+	_, httpServer := newTestServer(t)
+	client := NewClient[Board](httpServer.URL, nil)
+
+	applied, err := client.Poll(context.Background(), 10*time.Millisecond)
+	if err != nil || applied != 0 {
+		t.Errorf("Expected an empty poll, got %d (%v)", applied, err)
+	}
+}
+
+func TestClientPushAppliesOnServerAndOtherClients(t *testing.T) {
+	server, httpServer := newTestServer(t)
+	alice := NewClient[Board](httpServer.URL, nil)
+	bob := NewClient[Board](httpServer.URL, nil)
+	alice.Fetch(context.Background())
+	bob.Fetch(context.Background())
+
+	committed, err := alice.Push(context.Background(), []compare.Change{{Field: "Title", ChangeType: compare.Modified, NewValue: "Alice's board"}})
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if committed.Version != 1 {
+		t.Errorf("Expected version 1, got %d", committed.Version)
+	}
+
+	if value, version := server.State(); value.Title != "Alice's board" || version != 1 {
+		t.Errorf("Expected server to apply the push, got %+v at %d", value, version)
+	}
+	if value, version := alice.State(); value.Title != "Alice's board" || version != 1 {
+		t.Errorf("Expected pusher to apply its own change, got %+v at %d", value, version)
+	}
+
+	bob.Poll(context.Background(), 0)
+	if value, _ := bob.State(); value.Title != "Alice's board" {
+		t.Errorf("Expected other client to receive the push, got %+v", value)
+	}
+}
+
+func TestClientPushConflictsOnStaleVersion(t *testing.T) {
+	server, httpServer := newTestServer(t)
+	client := NewClient[Board](httpServer.URL, nil)
+	client.Fetch(context.Background())
+
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 3}}, "admin")
+
+	_, err := client.Push(context.Background(), []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 4}})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	client.Poll(context.Background(), 0)
+	if _, err := client.Push(context.Background(), []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 4}}); err != nil {
+		t.Errorf("Expected push to succeed after catching up: %v", err)
+	}
+	if value, _ := server.State(); value.Limit != 4 {
+		t.Errorf("Expected Limit 4 on the server, got %+v", value)
+	}
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	_, httpServer := newTestServer(t)
+
+	for _, path := range []string{"/changes?since=abc", "/changes?since=0&wait=soon"} {
+		response, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", path, response.StatusCode)
+		}
+	}
+
+	response, _ := http.Get(httpServer.URL + "/changes?since=9")
+	response.Body.Close()
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 for a version ahead of the server, got %d", response.StatusCode)
+	}
+
+	client := NewClient[Board](httpServer.URL, nil)
+	if _, err := client.Push(context.Background(), []compare.Change{{Field: "Missing", ChangeType: compare.Modified, NewValue: 1}}); err == nil {
+		t.Error("Expected error when pushing changes to an unknown field")
+	}
+}
+
+func TestClientFetchesStateWhenChangeSetsWereTrimmed(t *testing.T) {
+	server := NewServerWith(Board{Title: "Sprint"}, Options{History: 2})
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client := NewClient[Board](httpServer.URL, nil)
+	client.Fetch(context.Background())
+	for i := 1; i <= 3; i++ {
+		server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: i}}, "admin")
+	}
+
+	if _, err := client.Poll(context.Background(), 0); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if value, version := client.State(); value.Limit != 3 || version != 3 {
+		t.Errorf("Expected full state at version 3, got %+v at version %d", value, version)
+	}
+
+	// Clients within the kept history still catch up from change sets
+	server.Apply([]compare.Change{{Field: "Title", ChangeType: compare.Modified, NewValue: "Next"}}, "admin")
+	if applied, err := client.Poll(context.Background(), 0); err != nil || applied != 1 {
+		t.Errorf("Expected 1 change set applied, got %d (%v)", applied, err)
+	}
+}
+
+func TestServerRejectsChangeSetForAnotherType(t *testing.T) {
+	_, httpServer := newTestServer(t)
+
+	cs, err := compare.NewChangeSet(struct{ Limit int }{}, []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 1}})
+	if err != nil {
+		t.Fatalf("NewChangeSet failed: %v", err)
+	}
+	body, _ := compare.ChangeSetToJSON(cs)
+
+	response, err := http.Post(httpServer.URL+"/changes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a change set of another type, got %s", response.Status)
+	}
+}
+
+func TestServerRejectsOversizedPush(t *testing.T) {
+	_, httpServer := newTestServer(t)
+
+	body := append([]byte(`{"id": "`), bytes.Repeat([]byte("x"), maxPushSize)...)
+	response, err := http.Post(httpServer.URL+"/changes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized push, got %s", response.Status)
+	}
+}
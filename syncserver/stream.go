@@ -0,0 +1,531 @@
+package syncserver
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the highest stream protocol version this package speaks
+const ProtocolVersion = 1
+
+// maxFrameSize bounds the payload of a single frame
+const maxFrameSize = 16 << 20
+
+// streamWindow is how many change sets the server sends past the last version a peer acknowledged
+// before it waits for the peer's acks
+const streamWindow = 64
+
+// Frame types of the stream protocol
+const (
+	frameHello     = "hello"
+	frameChangeSet = "change_set"
+	frameAck       = "ack"
+	frameResync    = "resync"
+	frameSnapshot  = "snapshot"
+	frameReject    = "reject"
+	frameError     = "error"
+)
+
+// ErrProtocol is returned when the peer violates or cannot negotiate the stream protocol
+var ErrProtocol = errors.New("syncserver: protocol error")
+
+// frame is a single message on the stream. Every frame is written as a 4 byte big-endian length
+// followed by its JSON encoding.
+//
+//	hello       both sides, first frame: Protocol is the highest version spoken, Version the known state version
+//	change_set  server: a committed change set; client: a change set to commit on top of ParentVersion
+//	ack         client: Version has been applied; the server sends at most streamWindow change sets past it
+//	resync      client: local state is unusable, send a snapshot
+//	snapshot    server: the full State at Version
+//	reject      server: the client change set ID was not committed, Error says why and Conflict is set
+//	            when its ParentVersion was stale
+//	error       either side: fatal error, the connection is closed after it
+type frame struct {
+	Type      string             `json:"type"`
+	Protocol  int                `json:"protocol,omitempty"`
+	Version   uint64             `json:"version,omitempty"`
+	ID        string             `json:"id,omitempty"`
+	ChangeSet *compare.ChangeSet `json:"change_set,omitempty"`
+	State     json.RawMessage    `json:"state,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Conflict  bool               `json:"conflict,omitempty"`
+}
+
+// writeFrame - writes a length prefixed frame
+func writeFrame(w io.Writer, f frame) error {
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buffer, uint32(len(payload)))
+	copy(buffer[4:], payload)
+	_, err = w.Write(buffer)
+	return err
+}
+
+// readFrame - reads a length prefixed frame
+func readFrame(r io.Reader) (frame, error) {
+	var f frame
+
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return f, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return f, fmt.Errorf("%w: frame of %d bytes exceeds limit", ErrProtocol, size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(payload, &f); err != nil {
+		return f, fmt.Errorf("%w: %v", ErrProtocol, err)
+	}
+	return f, nil
+}
+
+// negotiate - picks the protocol version both sides speak
+func negotiate(peer frame) (int, error) {
+	if peer.Type != frameHello {
+		return 0, fmt.Errorf("%w: expected hello, got %q", ErrProtocol, peer.Type)
+	}
+	if peer.Protocol < 1 {
+		return 0, fmt.Errorf("%w: unsupported protocol version %d", ErrProtocol, peer.Protocol)
+	}
+	return min(peer.Protocol, ProtocolVersion), nil
+}
+
+// ServeStream keeps the peer on rw in sync with the server until ctx is done or the stream fails.
+// The caller owns rw and should close it afterwards to stop the background reader.
+// After the hello exchange the peer receives the change sets it is missing (or a snapshot when its
+// version is unknown to the server or older than the kept change sets), then every change set as it is committed, pausing
+// while the peer has not acknowledged the last streamWindow of them. Change sets sent by the peer are committed like
+// HTTP pushes; a stale ParentVersion is answered with a reject frame.
+func (s *Server[T]) ServeStream(ctx context.Context, rw io.ReadWriter) error {
+	hello, err := readFrame(rw)
+	if err != nil {
+		return err
+	}
+	protocol, err := negotiate(hello)
+	if err != nil {
+		writeFrame(rw, frame{Type: frameError, Error: err.Error()})
+		return err
+	}
+
+	_, version := s.State()
+	if err := writeFrame(rw, frame{Type: frameHello, Protocol: protocol, Version: version}); err != nil {
+		return err
+	}
+
+	// Queue frames from the peer for the loop below so that all writes happen on this goroutine.
+	// The queue is unbounded so the peer's acks never block behind a write to the peer.
+	queue := &frameQueue{ready: make(chan struct{}, 1)}
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			f, err := readFrame(rw)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			queue.push(f)
+		}
+	}()
+
+	// sent is the version the peer has been sent up to, acked the version it has confirmed
+	sent, acked := hello.Version, hello.Version
+	if sent > version {
+		if sent, err = s.sendSnapshot(rw); err != nil {
+			return err
+		}
+	}
+
+	for {
+		current, changeSets, changed, ok := s.since(sent)
+		if !ok {
+			// The peer is further behind than the kept change sets reach
+			if sent, err = s.sendSnapshot(rw); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, cs := range changeSets {
+			if cs.Version > acked+streamWindow {
+				break
+			}
+			if err := writeFrame(rw, frame{Type: frameChangeSet, ChangeSet: &cs}); err != nil {
+				return err
+			}
+			sent = cs.Version
+		}
+
+		// With change sets held back, only the peer's acks can let the loop continue
+		if sent < current {
+			changed = nil
+		}
+
+		select {
+		case <-changed:
+		case <-queue.ready:
+			for _, f := range queue.drain() {
+				if err := s.handleFrame(rw, f, &sent, &acked); err != nil {
+					if errors.Is(err, ErrProtocol) && f.Type != frameError {
+						writeFrame(rw, frame{Type: frameError, Error: err.Error()})
+					}
+					return err
+				}
+			}
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// frameQueue is an unbounded queue of frames read from a peer
+type frameQueue struct {
+	mu     sync.Mutex
+	frames []frame
+	ready  chan struct{}
+}
+
+// push - queues a frame and signals the consumer
+func (q *frameQueue) push(f frame) {
+	q.mu.Lock()
+	q.frames = append(q.frames, f)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// drain - takes every queued frame
+func (q *frameQueue) drain() []frame {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	frames := q.frames
+	q.frames = nil
+	return frames
+}
+
+// handleFrame - handles a frame from a stream peer
+func (s *Server[T]) handleFrame(w io.Writer, f frame, sent, acked *uint64) error {
+	switch f.Type {
+	case frameAck:
+		if f.Version > *sent {
+			return fmt.Errorf("%w: ack for version %d, only %d was sent", ErrProtocol, f.Version, *sent)
+		}
+		*acked = max(*acked, f.Version)
+		return nil
+	case frameResync:
+		version, err := s.sendSnapshot(w)
+		*sent = version
+		return err
+	case frameChangeSet:
+		if f.ChangeSet == nil {
+			return fmt.Errorf("%w: change_set frame without change set", ErrProtocol)
+		}
+
+		s.mu.Lock()
+		var err error
+		conflict := f.ChangeSet.ParentVersion != s.version()
+		if conflict {
+			err = fmt.Errorf("parent version %d does not match current version %d", f.ChangeSet.ParentVersion, s.version())
+		} else {
+			_, err = s.commit(*f.ChangeSet)
+		}
+		s.mu.Unlock()
+
+		// Committed change sets reach the peer through the regular broadcast
+		if err != nil {
+			return writeFrame(w, frame{Type: frameReject, ID: f.ChangeSet.ID, Error: err.Error(), Conflict: conflict})
+		}
+		return nil
+	case frameError:
+		return fmt.Errorf("%w: peer error: %s", ErrProtocol, f.Error)
+	default:
+		return fmt.Errorf("%w: unexpected frame %q", ErrProtocol, f.Type)
+	}
+}
+
+// sendSnapshot - sends the full state and returns the version it was taken at
+func (s *Server[T]) sendSnapshot(w io.Writer) (uint64, error) {
+	value, version := s.State()
+	state, err := json.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+	return version, writeFrame(w, frame{Type: frameSnapshot, Version: version, State: state})
+}
+
+// StreamClient keeps a local copy of a Server's state up to date over a stream
+type StreamClient[T any] struct {
+	mu      sync.Mutex
+	value   T
+	version uint64
+	applied chan struct{}
+
+	// ready is closed once the hello exchange has completed
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	// Pushed change sets waiting to be committed or rejected, by ID, and committed ones that did not
+	// apply locally and wait for the snapshot requested instead
+	pending   map[string]chan error
+	resyncing []chan error
+
+	writeMu sync.Mutex
+	writer  io.Writer
+}
+
+// NewStreamClient creates a client starting from initial at version, typically the zero value at 0
+func NewStreamClient[T any](initial T, version uint64) *StreamClient[T] {
+	return &StreamClient[T]{
+		value:   initial,
+		version: version,
+		applied: make(chan struct{}),
+		ready:   make(chan struct{}),
+		pending: make(map[string]chan error),
+	}
+}
+
+// State returns the local value and the server version it corresponds to
+func (c *StreamClient[T]) State() (T, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value, c.version
+}
+
+// Run performs the hello exchange on rw and applies everything the server sends until the stream
+// ends or fails. It returns nil when the server closes the stream.
+func (c *StreamClient[T]) Run(rw io.ReadWriter) error {
+	_, version := c.State()
+	if err := writeFrame(rw, frame{Type: frameHello, Protocol: ProtocolVersion, Version: version}); err != nil {
+		return err
+	}
+
+	hello, err := readFrame(rw)
+	if err != nil {
+		return err
+	}
+	if hello.Type == frameError {
+		return fmt.Errorf("%w: %s", ErrProtocol, hello.Error)
+	}
+	if _, err := negotiate(hello); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	c.writer = rw
+	c.writeMu.Unlock()
+	c.readyOnce.Do(func() { close(c.ready) })
+
+	defer c.failPending(io.ErrUnexpectedEOF)
+	for {
+		f, err := readFrame(rw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.handleFrame(f); err != nil {
+			return err
+		}
+	}
+}
+
+// Push sends changes to the server as the next version after the local one and waits until the server
+// has committed them and the local state includes them, or the server has rejected them. A rejection
+// caused by a concurrent change returns ErrConflict.
+// Push waits for Run to complete the hello exchange first.
+func (c *StreamClient[T]) Push(ctx context.Context, changes []compare.Change) error {
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	cs, err := compare.NewChangeSet(c.value, changes)
+	cs.ParentVersion = c.version
+	result := make(chan error, 1)
+	if err == nil {
+		c.pending[cs.ID] = result
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := c.send(frame{Type: frameChangeSet, ChangeSet: &cs}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, cs.ID)
+		c.mu.Unlock()
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, cs.ID)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// WaitVersion blocks until the local state has reached at least version
+func (c *StreamClient[T]) WaitVersion(ctx context.Context, version uint64) error {
+	for {
+		c.mu.Lock()
+		current, applied := c.version, c.applied
+		c.mu.Unlock()
+		if current >= version {
+			return nil
+		}
+
+		select {
+		case <-applied:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handleFrame - handles a frame from the server
+func (c *StreamClient[T]) handleFrame(f frame) error {
+	switch f.Type {
+	case frameChangeSet:
+		if f.ChangeSet == nil {
+			return fmt.Errorf("%w: change_set frame without change set", ErrProtocol)
+		}
+		return c.applyChangeSet(*f.ChangeSet)
+	case frameSnapshot:
+		var value T
+		if err := json.Unmarshal(f.State, &value); err != nil {
+			return fmt.Errorf("cannot decode snapshot: %w", err)
+		}
+		c.mu.Lock()
+		c.value, c.version = value, f.Version
+		c.notifyApplied()
+		for _, result := range c.resyncing {
+			result <- nil
+		}
+		c.resyncing = nil
+		c.mu.Unlock()
+		return c.send(frame{Type: frameAck, Version: f.Version})
+	case frameReject:
+		if f.Conflict {
+			c.resolve(f.ID, fmt.Errorf("%w: %s", ErrConflict, f.Error))
+		} else {
+			c.resolve(f.ID, fmt.Errorf("syncserver: change set rejected: %s", f.Error))
+		}
+		return nil
+	case frameError:
+		return fmt.Errorf("%w: peer error: %s", ErrProtocol, f.Error)
+	default:
+		return fmt.Errorf("%w: unexpected frame %q", ErrProtocol, f.Type)
+	}
+}
+
+// applyChangeSet - applies a committed change set, asking for a snapshot when it does not fit
+func (c *StreamClient[T]) applyChangeSet(cs compare.ChangeSet) error {
+	c.mu.Lock()
+	if cs.Version <= c.version {
+		// Already covered by a snapshot
+		c.mu.Unlock()
+		c.resolve(cs.ID, nil)
+		return nil
+	}
+
+	err := c.apply(cs)
+	version := c.version
+	if result, ok := c.pending[cs.ID]; ok && err != nil {
+		// The server committed our push, so it completes once the snapshot has replaced the local state
+		delete(c.pending, cs.ID)
+		c.resyncing = append(c.resyncing, result)
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		return c.send(frame{Type: frameResync, Version: version})
+	}
+	c.resolve(cs.ID, nil)
+	return c.send(frame{Type: frameAck, Version: version})
+}
+
+// apply - applies a change set on top of the local version, the caller must hold the lock
+func (c *StreamClient[T]) apply(cs compare.ChangeSet) error {
+	if cs.ParentVersion != c.version {
+		return fmt.Errorf("change set %s continues version %d, local version is %d", cs.ID, cs.ParentVersion, c.version)
+	}
+
+	client := Client[T]{value: c.value, version: c.version}
+	if err := client.apply(cs); err != nil {
+		return err
+	}
+	c.value, c.version = client.value, client.version
+	c.notifyApplied()
+	return nil
+}
+
+// notifyApplied - wakes WaitVersion callers, the caller must hold the lock
+func (c *StreamClient[T]) notifyApplied() {
+	close(c.applied)
+	c.applied = make(chan struct{})
+}
+
+// resolve - completes a pending Push
+func (c *StreamClient[T]) resolve(id string, err error) {
+	c.mu.Lock()
+	result, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+
+	if ok {
+		result <- err
+	}
+}
+
+// failPending - fails every pending Push when the stream ends
+func (c *StreamClient[T]) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, result := range c.pending {
+		result <- err
+		delete(c.pending, id)
+	}
+	for _, result := range c.resyncing {
+		result <- err
+	}
+	c.resyncing = nil
+}
+
+// send - writes a frame to the server
+func (c *StreamClient[T]) send(f frame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writer == nil {
+		return fmt.Errorf("syncserver: stream not running")
+	}
+	return writeFrame(c.writer, f)
+}
@@ -0,0 +1,309 @@
+package syncserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/rschoonheim/go-struct-sync/compare"
+	"net"
+	"testing"
+	"time"
+)
+
+// connect runs a stream between server and a new client over net.Pipe until the test ends
+func connect(t *testing.T, server *Server[Board], initial Board, version uint64) *StreamClient[Board] {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		serverConn.Close()
+		clientConn.Close()
+	})
+
+	go server.ServeStream(ctx, serverConn)
+
+	client := NewStreamClient(initial, version)
+	go client.Run(clientConn)
+	return client
+}
+
+// waitFor waits until the client has reached version
+func waitFor(t *testing.T, client *StreamClient[Board], version uint64) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.WaitVersion(ctx, version); err != nil {
+		t.Fatalf("Client did not reach version %d: %v", version, err)
+	}
+}
+
+func TestStreamClientCatchesUpAndFollows(t *testing.T) {
+	server := NewServer(Board{Title: "Sprint"})
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 3}}, "admin")
+
+	client := connect(t, server, Board{Title: "Sprint"}, 0)
+	waitFor(t, client, 1)
+
+	for i := 0; i < 5; i++ {
+		server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 10 + i}}, "admin")
+	}
+	waitFor(t, client, 6)
+
+	if value, _ := client.State(); value.Limit != 14 || value.Title != "Sprint" {
+		t.Errorf("Unexpected client state %+v", value)
+	}
+}
+
+func TestStreamClientWithUnknownVersionGetsSnapshot(t *testing.T) {
+	server := NewServer(Board{Title: "Sprint", Columns: []string{"todo", "done"}})
+
+	client := connect(t, server, Board{}, 42)
+
+	// The snapshot resets the client back to the server's version 0
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if value, version := client.State(); version == 0 && value.Title == "Sprint" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a snapshot, got %+v", client)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if value, _ := client.State(); len(value.Columns) != 2 {
+		t.Errorf("Unexpected snapshot state %+v", value)
+	}
+}
+
+func TestStreamPushReachesServerAndOtherPeers(t *testing.T) {
+	server := NewServer(Board{Title: "Sprint"})
+	alice := connect(t, server, Board{Title: "Sprint"}, 0)
+	bob := connect(t, server, Board{Title: "Sprint"}, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := alice.Push(ctx, []compare.Change{{Field: "Columns", ChangeType: compare.Added, NewValue: []string{"todo"}}}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if value, version := alice.State(); version != 1 || len(value.Columns) != 1 {
+		t.Errorf("Expected pusher at version 1, got %+v at %d", value, version)
+	}
+	if value, _ := server.State(); len(value.Columns) != 1 {
+		t.Errorf("Expected server to commit the push, got %+v", value)
+	}
+
+	waitFor(t, bob, 1)
+	if value, _ := bob.State(); len(value.Columns) != 1 || value.Columns[0] != "todo" {
+		t.Errorf("Expected other peer to receive the push, got %+v", value)
+	}
+}
+
+// rawPeer connects a bare protocol peer to server and completes the hello exchange at version
+func rawPeer(t *testing.T, server *Server[Board], version uint64) net.Conn {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() {
+		serverConn.Close()
+		clientConn.Close()
+	})
+	go server.ServeStream(context.Background(), serverConn)
+
+	if err := writeFrame(clientConn, frame{Type: frameHello, Protocol: ProtocolVersion, Version: version}); err != nil {
+		t.Fatalf("writing hello failed: %v", err)
+	}
+	if hello, err := readFrame(clientConn); err != nil || hello.Type != frameHello {
+		t.Fatalf("Expected hello, got %+v (%v)", hello, err)
+	}
+	return clientConn
+}
+
+func TestStreamPushRejectedOnStaleVersion(t *testing.T) {
+	server := NewServer(Board{})
+	conn := rawPeer(t, server, 0)
+
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 1}}, "admin")
+	if f, err := readFrame(conn); err != nil || f.Type != frameChangeSet || f.ChangeSet.Version != 1 {
+		t.Fatalf("Expected change set 1, got %+v (%v)", f, err)
+	}
+
+	stale := compare.ChangeSet{ID: "stale", ParentVersion: 0, Changes: []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 2}}}
+	writeFrame(conn, frame{Type: frameChangeSet, ChangeSet: &stale})
+
+	f, err := readFrame(conn)
+	if err != nil || f.Type != frameReject || f.ID != "stale" {
+		t.Fatalf("Expected a reject frame, got %+v (%v)", f, err)
+	}
+	if value, _ := server.State(); value.Limit != 1 {
+		t.Errorf("Expected the stale push not to be committed, got %+v", value)
+	}
+}
+
+func TestStreamResyncSendsSnapshot(t *testing.T) {
+	server := NewServer(Board{Title: "Sprint"})
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 5}}, "admin")
+
+	conn := rawPeer(t, server, 1)
+	writeFrame(conn, frame{Type: frameResync, Version: 1})
+
+	f, err := readFrame(conn)
+	if err != nil || f.Type != frameSnapshot || f.Version != 1 {
+		t.Fatalf("Expected a snapshot at version 1, got %+v (%v)", f, err)
+	}
+	if !bytes.Contains(f.State, []byte(`"Limit":5`)) {
+		t.Errorf("Unexpected snapshot state %s", f.State)
+	}
+}
+
+func TestServeStreamRejectsBadHello(t *testing.T) {
+	server := NewServer(Board{})
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.ServeStream(context.Background(), serverConn) }()
+
+	writeFrame(clientConn, frame{Type: frameHello, Protocol: 0})
+	reply, err := readFrame(clientConn)
+	if err != nil || reply.Type != frameError {
+		t.Errorf("Expected an error frame, got %+v (%v)", reply, err)
+	}
+	if err := <-errs; !errors.Is(err, ErrProtocol) {
+		t.Errorf("Expected ErrProtocol, got %v", err)
+	}
+}
+
+func TestStreamClientRejectedPushReturnsConflict(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	client := NewStreamClient(Board{}, 0)
+	go client.Run(clientConn)
+
+	// Play the server side by hand and reject whatever is pushed
+	go func() {
+		readFrame(serverConn)
+		writeFrame(serverConn, frame{Type: frameHello, Protocol: ProtocolVersion})
+		pushed, err := readFrame(serverConn)
+		if err == nil && pushed.ChangeSet != nil {
+			writeFrame(serverConn, frame{Type: frameReject, ID: pushed.ChangeSet.ID, Error: "stale", Conflict: true})
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := client.Push(ctx, []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 1}})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestStreamPushRejectedByServer(t *testing.T) {
+	server := NewServer(Board{Title: "Sprint"})
+	client := connect(t, server, Board{Title: "Sprint"}, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := client.Push(ctx, []compare.Change{{Field: "Missing", ChangeType: compare.Modified, NewValue: 1}})
+	if err == nil || errors.Is(err, ErrConflict) || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the push to be rejected without a conflict, got %v", err)
+	}
+	if _, version := server.State(); version != 0 {
+		t.Errorf("Expected nothing to be committed, got version %d", version)
+	}
+}
+
+func TestStreamPushThatDoesNotApplyLocallyWaitsForSnapshot(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	client := NewStreamClient(Board{}, 0)
+	go client.Run(clientConn)
+
+	// Play the server side by hand and commit the push as a change set the client cannot apply
+	go func() {
+		readFrame(serverConn)
+		writeFrame(serverConn, frame{Type: frameHello, Protocol: ProtocolVersion})
+		pushed, err := readFrame(serverConn)
+		if err != nil || pushed.ChangeSet == nil {
+			return
+		}
+		committed := compare.ChangeSet{ID: pushed.ChangeSet.ID, ParentVersion: 5, Version: 6}
+		writeFrame(serverConn, frame{Type: frameChangeSet, ChangeSet: &committed})
+		if resync, err := readFrame(serverConn); err != nil || resync.Type != frameResync {
+			return
+		}
+		writeFrame(serverConn, frame{Type: frameSnapshot, Version: 6, State: []byte(`{"Limit":1}`)})
+		readFrame(serverConn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Push(ctx, []compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 1}}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if value, version := client.State(); version != 6 || value.Limit != 1 {
+		t.Errorf("Expected the snapshot at version 6, got %+v at %d", value, version)
+	}
+}
+
+func TestServeStreamWaitsForAcks(t *testing.T) {
+	server := NewServer(Board{})
+	conn := rawPeer(t, server, 0)
+
+	for i := 1; i <= streamWindow+1; i++ {
+		server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: i}}, "admin")
+	}
+	for i := 1; i <= streamWindow; i++ {
+		if f, err := readFrame(conn); err != nil || f.Type != frameChangeSet || f.ChangeSet.Version != uint64(i) {
+			t.Fatalf("Expected change set %d, got %+v (%v)", i, f, err)
+		}
+	}
+
+	// The last change set is held back until the peer acknowledges
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if f, err := readFrame(conn); err == nil {
+		t.Fatalf("Expected no frame before the ack, got %+v", f)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	writeFrame(conn, frame{Type: frameAck, Version: streamWindow})
+	if f, err := readFrame(conn); err != nil || f.Type != frameChangeSet || f.ChangeSet.Version != streamWindow+1 {
+		t.Fatalf("Expected change set %d after the ack, got %+v (%v)", streamWindow+1, f, err)
+	}
+
+	writeFrame(conn, frame{Type: frameAck, Version: streamWindow + 2})
+	if f, err := readFrame(conn); err != nil || f.Type != frameError {
+		t.Errorf("Expected an error frame for an ack of an unsent version, got %+v (%v)", f, err)
+	}
+}
+
+func TestReadFrameRejectsOversizedFrames(t *testing.T) {
+	data := []byte{0xff, 0xff, 0xff, 0xff}
+	if _, err := readFrame(bytes.NewReader(data)); !errors.Is(err, ErrProtocol) {
+		t.Errorf("Expected ErrProtocol for an oversized frame, got %v", err)
+	}
+}
+
+func TestStreamClientBehindTrimmedHistoryGetsSnapshot(t *testing.T) {
+	server := NewServerWith(Board{Title: "Sprint"}, Options{History: 1})
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 1}}, "admin")
+	server.Apply([]compare.Change{{Field: "Limit", ChangeType: compare.Modified, NewValue: 2}}, "admin")
+
+	client := connect(t, server, Board{Title: "Sprint"}, 0)
+	waitFor(t, client, 2)
+
+	if value, _ := client.State(); value.Limit != 2 {
+		t.Errorf("Unexpected client state %+v", value)
+	}
+}